@@ -0,0 +1,146 @@
+package cli
+
+import "strconv"
+
+// The methods in this file read the parsed Command passed to an Action. Flags and Options
+// can be named by either their short or long name, and Arguments by their Name. The String
+// method prints the Command, so the value of an option is read with Value instead.
+
+// IsSet reports whether the flag, option, or argument called name has a value from any
+// source, including its default. A flag that was turned off is set, so use Bool to tell
+// whether it is on.
+func (c Command) IsSet(name string) bool {
+	return c.parsedFlags(name) != nil || c.parsedOptions(name) != nil || c.countArg(name) > 0
+}
+
+// Changed reports whether the flag, option, or argument called name was given on the
+// command line, as opposed to coming from the environment, a config file, or a default.
+func (c Command) Changed(name string) bool {
+	for _, f := range c.parsedFlags(name) {
+		if f.Source == SourceUser {
+			return true
+		}
+	}
+	for _, o := range c.parsedOptions(name) {
+		if o.Source == SourceUser {
+			return true
+		}
+	}
+	for _, a := range c.Args {
+		if a.Name == name && a.Source == SourceUser {
+			return true
+		}
+	}
+	return false
+}
+
+// Bool reports whether the flag called name is on.
+func (c Command) Bool(name string) bool {
+	flags := c.parsedFlags(name)
+	return flags != nil && !flags[0].Negated
+}
+
+// Value returns the value of the option called name, or "" when it is not set.
+func (c Command) Value(name string) string {
+	opts := c.parsedOptions(name)
+	if opts == nil {
+		return ""
+	}
+	return opts[0].Value
+}
+
+// Int returns the value of the option called name as an int. It returns 0 when the option
+// is not set or is not an integer.
+func (c Command) Int(name string) int {
+	opts := c.parsedOptions(name)
+	if opts == nil {
+		return 0
+	}
+	switch val := opts[0].TypedValue.(type) {
+	case int:
+		return val
+	case int64:
+		return int(val)
+	}
+	i, _ := strconv.Atoi(opts[0].Value)
+	return i
+}
+
+// Strings returns every value given for the option called name, in order. Values of a
+// StringSliceType option are split into their elements.
+func (c Command) Strings(name string) (vals []string) {
+	for _, o := range c.parsedOptions(name) {
+		if slice, ok := o.TypedValue.([]string); ok {
+			vals = append(vals, slice...)
+		} else {
+			vals = append(vals, o.Value)
+		}
+	}
+	return vals
+}
+
+// Count returns the number of times the flag called name was given, e.g. 3 for "-vvv".
+// A flag set from the environment, a config file, or a default counts once.
+func (c Command) Count(name string) (n int) {
+	for _, f := range c.parsedFlags(name) {
+		if f.Count > 1 {
+			n += f.Count
+		} else if !f.Negated {
+			n++
+		}
+	}
+	return n
+}
+
+// Arg returns the value bound to the argument called name, or "" when it is not set. A
+// Variadic argument returns its first value.
+func (c Command) Arg(name string) string {
+	vals := c.ArgValues(name)
+	if vals == nil {
+		return ""
+	}
+	return vals[0]
+}
+
+// ArgValues returns every value bound to the argument called name, in order.
+func (c Command) ArgValues(name string) (vals []string) {
+	for _, a := range c.Args {
+		if a.Name == name {
+			vals = append(vals, a.Value)
+		}
+	}
+	return vals
+}
+
+// parsedFlags returns the entries of a parsed Command for the flag called name. The
+// definition the Command was parsed against maps a short name to its long name and back.
+func (c Command) parsedFlags(name string) (flags []Flag) {
+	def := Flag{ShortName: name, LongName: name}
+	if c.def != nil {
+		if f, found := c.def.findFlag(name); found {
+			def = f
+		}
+	}
+	for _, f := range c.Flags {
+		if sameFlag(f, def) {
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+// parsedOptions returns the entries of a parsed Command for the option called name.
+func (c Command) parsedOptions(name string) (opts []Option) {
+	def := Option{ShortName: name, LongName: name}
+	if c.def != nil {
+		if o, found := c.def.findOption(name); found {
+			def = o
+		}
+	}
+	for _, o := range c.Opts {
+		if sameOption(o, def) {
+			opts = append(opts, o)
+		}
+	}
+	return opts
+}
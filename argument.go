@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -9,6 +10,72 @@ type Argument struct {
 	Name        string
 	Value       string
 	Description string
+
+	// Type converts Value when the command line is parsed. The result is stored in
+	// TypedValue. A nil Type leaves the value as a string.
+	Type       ValueParser
+	TypedValue interface{}
+
+	// Required makes Run fail before any PreAction when the argument is not given.
+	Required bool
+
+	// Default is used as the Value when the user does not give the argument. Source records
+	// where the Value of a parsed argument came from.
+	Default string
+	Source  Source
+
+	// Complete returns the candidates offered for the argument during shell completion.
+	Complete CompletionFunc
+
+	// Choices, Pattern, Range, and Validate limit the values the argument accepts, the same
+	// as they do for an Option.
+	Choices  []string
+	Pattern  string
+	Range    *Range
+	Validate ValidatorFunc
+
+	// Variadic lets the argument take any number of values, each bound to its own entry in
+	// the parsed Args. A signature may have one Variadic argument. The arguments in front of
+	// it are filled first, then the ones after it, and it takes the values left over. Min
+	// and Max limit how many values it takes, with a Max of 0 meaning no limit.
+	Variadic bool
+	Min      int
+	Max      int
+}
+
+func (arg *Argument) rules() valueRules {
+	return valueRules{arg.Choices, arg.Pattern, arg.Range, arg.Validate}
+}
+
+// label returns the name of the argument as it is shown in help and errors, e.g. "<src>"
+// or "<src>..." when it is Variadic.
+func (arg Argument) label() string {
+	if arg.Variadic {
+		return fmt.Sprintf("<%s>...", arg.Name)
+	}
+	return fmt.Sprintf("<%s>", arg.Name)
+}
+
+// minValues returns the fewest values the argument must be given.
+func (arg Argument) minValues() int {
+	if arg.Variadic && arg.Min > 0 {
+		return arg.Min
+	}
+	if arg.Required {
+		return 1
+	}
+	return 0
+}
+
+// maxValues returns the most values the argument can be given, or -1 for no limit.
+func (arg Argument) maxValues() int {
+	if !arg.Variadic {
+		return 1
+	}
+	if arg.Max > 0 {
+		return arg.Max
+	}
+	return -1
 }
 
 func (arg *Argument) String() string {
@@ -39,3 +106,152 @@ func ArgSetArrayToStringArray(argSetArr []ArgumentSet) (strArr []string) {
 
 	return strArr
 }
+
+// positionalArgs returns the Arguments of the set that are filled by position.
+func (argSet ArgumentSet) positionalArgs() []Argument {
+	return Command{Args: argSet.Set}.positionalArgs()
+}
+
+// argCounts returns the fewest and most positional values defs take. max is -1 when
+// there is no limit.
+func argCounts(defs []Argument) (min int, max int) {
+	for _, def := range defs {
+		min += def.minValues()
+		if max >= 0 && def.maxValues() >= 0 {
+			max += def.maxValues()
+		} else {
+			max = -1
+		}
+	}
+	return min, max
+}
+
+// argsFit reports whether n positional values are enough for every Required Argument of
+// defs without being more than defs can hold.
+func argsFit(defs []Argument, n int) bool {
+	min, max := argCounts(defs)
+	return min <= n && (max < 0 || n <= max)
+}
+
+// argNames returns the name of the Argument in defs that each of n positional values
+// fills, or "" for values beyond the last Argument. Every Argument first gets its minimum
+// so that Required ones are filled even when an optional one comes in front of them. The
+// values left over go to the optional Arguments in front of a Variadic one, then to those
+// after it, and the Variadic one takes the rest.
+func argNames(defs []Argument, n int) (names []string) {
+	counts := make([]int, len(defs))
+	left := n
+	for i, def := range defs {
+		counts[i] = minInt(def.minValues(), left)
+		left -= counts[i]
+	}
+	for i, def := range defs {
+		if !def.Variadic && counts[i] < 1 && left > 0 {
+			counts[i]++
+			left--
+		}
+	}
+	for i, def := range defs {
+		if def.Variadic {
+			take := left
+			if def.maxValues() >= 0 {
+				take = minInt(left, def.maxValues()-counts[i])
+			}
+			counts[i] += take
+			left -= take
+		}
+	}
+
+	for i, def := range defs {
+		for j := 0; j < counts[i]; j++ {
+			names = append(names, def.Name)
+		}
+	}
+	for ; left > 0; left-- {
+		names = append(names, "")
+	}
+	return names
+}
+
+// matchArgSet returns the signature of c that n positional values fit, as stored in
+// MatchedArgSet: 0 for the Args of c, or 1 plus the index of the first ArgumentSet they
+// fit. The Args of c are only tried first when they take a positional value or when c has
+// no ArgSets. found is false when nothing fits. Without ArgSets only too many values fail
+// to fit, leaving missing ones to CheckRequired.
+func (c Command) matchArgSet(n int) (set int, found bool) {
+	if c.ArgSets == nil {
+		_, max := argCounts(c.positionalArgs())
+		return 0, max < 0 || n <= max
+	}
+	if args := c.positionalArgs(); args != nil && argsFit(args, n) {
+		return 0, true
+	}
+	for i, argSet := range c.ArgSets {
+		if argsFit(argSet.positionalArgs(), n) {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// matchedArgs returns the positional Arguments of the signature set, as stored in
+// MatchedArgSet. Any set that does not name one of the ArgSets means the Args of c.
+func (c Command) matchedArgs(set int) []Argument {
+	if set < 1 || set > len(c.ArgSets) {
+		return c.positionalArgs()
+	}
+	return c.ArgSets[set-1].positionalArgs()
+}
+
+// argUsages returns one usage line for each positional signature of c, beginning with
+// comPath. Required Arguments are shown as <name> and the rest as [<name>].
+func (c Command) argUsages(comPath string) (usages []string) {
+	sets := [][]Argument{c.positionalArgs()}
+	for _, argSet := range c.ArgSets {
+		sets = append(sets, argSet.positionalArgs())
+	}
+	for i, set := range sets {
+		if i == 0 && set == nil && c.ArgSets != nil {
+			continue
+		}
+		usage := comPath
+		for _, arg := range set {
+			if arg.minValues() > 0 {
+				usage += " " + arg.label()
+			} else {
+				usage += fmt.Sprintf(" [%s]", arg.label())
+			}
+		}
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// bindArgs picks the positional signature of c that the values in userCom fit, records it
+// in userCom.MatchedArgSet, and names each positional value after the Argument it fills.
+func bindArgs(c Command, userCom *Command) error {
+	numValues := len(userCom.positionalValues(c))
+	set, found := c.matchArgSet(numValues)
+	if !found && c.ArgSets == nil {
+		_, max := argCounts(c.positionalArgs())
+		errStr := fmt.Sprintf("cli: too many arguments for %s: expected at most %d but got %d", c.Name, max, numValues)
+		return errors.New(errStr)
+	}
+	if !found {
+		errStr := fmt.Sprintf("cli: Arguments for %s match none of its usages:\n  %s", c.Name, strings.Join(c.argUsages(c.Name), "\n  "))
+		return errors.New(errStr)
+	}
+	userCom.MatchedArgSet = set
+
+	names := argNames(c.matchedArgs(set), numValues)
+	pos := 0
+	for i := range userCom.Args {
+		arg := &userCom.Args[i]
+		if c.hasArg(arg.Value) {
+			continue
+		}
+		arg.Name = names[pos]
+		pos++
+	}
+	return nil
+}
@@ -1,9 +1,12 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type SharedParameters struct {
@@ -11,8 +14,12 @@ type SharedParameters struct {
 	Args       []Argument
 	ArgSets    []ArgumentSet
 	Opts       []Option
-	PreAction  func(com Command) error
-	PostAction func(com Command) error
+	PreAction  ActionFunc
+	PostAction ActionFunc
+
+	// Middleware wraps the Action of every command, outside of the Middleware of the
+	// commands themselves.
+	Middleware []Middleware
 }
 
 type CommandTree struct {
@@ -24,11 +31,49 @@ type CommandTree struct {
 	Version      string
 	AutoHelp     bool
 	ToHelpString func(c Command, pathToCom []string) string
+
+	// HelpTemplate replaces DefaultHelpTemplate for every command in the tree that does not
+	// have a template of its own. It is only used when ToHelpString is nil.
+	HelpTemplate string
+
+	// ConfigFiles are read in order to fill in options that were not given on the command
+	// line or in the environment. Files that do not exist are skipped and later files
+	// override earlier ones, so list them from system wide to project local. The format
+	// is chosen by extension: .json, .toml, .yaml, or .ini. Sections map onto the path to
+	// a command, so "[quick.brown] color = x" sets --color for "the quick brown".
+	ConfigFiles []string
+
+	// AutoConfig adds a --config option for naming one more file to read after
+	// ConfigFiles, and a --show-config flag that prints the value of each option along
+	// with where it came from.
+	AutoConfig bool
+
+	// PrefixMatching lets the user shorten subcommands and long flags and options to any
+	// prefix that matches only one of them, e.g. "dep" for "deploy".
+	PrefixMatching bool
+
+	// DisableSuggestions turns off the "did you mean" check for mistyped subcommands.
+	// SuggestionDistance is the largest number of edits for which a subcommand is
+	// suggested, 2 when left at zero.
+	DisableSuggestions bool
+	SuggestionDistance int
+
+	// GracePeriod is how long the Action has to return after RunContext cancels its context
+	// on a signal. Once it is over, or on a second signal, the program exits through Exit
+	// with 130 for SIGINT or 143 for SIGTERM. A GracePeriod of zero waits for the second
+	// signal. Exit is os.Exit when left nil.
+	GracePeriod time.Duration
+	Exit        func(code int)
+
+	// EnvPrefix turns on environment variables for every Flag and Option that does not
+	// name its own. The name is the prefix followed by the path to the command and the
+	// name of the input, so "MYTOOL_" gives MYTOOL_QUICK_BROWN_COLOR for "the quick brown
+	// --color".
+	EnvPrefix string
 }
 
 func NewCommandTree() (tree CommandTree) {
 	tree.AutoHelp = true
-	tree.ToHelpString = ToHelpString
 	return tree
 }
 
@@ -37,19 +82,50 @@ var autoHelpFlag = Flag{
 	LongName:    "help",
 	Description: "Show help",
 }
+
+// isAutoFlag reports whether f is one of the flags added by AutoHelp or AutoConfig.
+func isAutoFlag(f Flag) bool {
+	return (f.ShortName == autoHelpFlag.ShortName && f.LongName == autoHelpFlag.LongName) ||
+		f.LongName == autoShowConfigFlag.LongName
+}
+
+// isAutoOption reports whether o is the option added by AutoConfig.
+func isAutoOption(o Option) bool {
+	return o.LongName == autoConfigOpt.LongName && o.ShortName == ""
+}
+
 var autoHelpArg = Argument{
 	Name:        "?",
 	Value:       "?",
 	Description: "Show help",
 }
 
+// Run finds the command named by appArgs, parses the rest of the command line against it,
+// and runs its Action. The Command given to the Action returns context.Background() from
+// its Context method. Use RunContext to pass a context and to cancel it on a signal.
 func Run(appArgs []string, tree *CommandTree) (err error) {
-	if tree.AutoHelp {
-		tree.Shared.Args = append(tree.Shared.Args, autoHelpArg)
-		tree.Shared.Flags = append(tree.Shared.Flags, autoHelpFlag)
+	return run(context.Background(), appArgs, tree)
+}
+
+// RunContext is Run with a context that the Command given to each hook and Action returns
+// from its Context method, and that a ContextAction is called with. The context is
+// cancelled when the program receives SIGINT or SIGTERM. A second signal, or the end of
+// tree.GracePeriod after the first, exits the program through tree.Exit.
+func RunContext(ctx context.Context, appArgs []string, tree *CommandTree) (err error) {
+	ctx, stop := tree.handleSignals(ctx)
+	defer stop()
+	return run(ctx, appArgs, tree)
+}
+
+func run(ctx context.Context, appArgs []string, tree *CommandTree) (err error) {
+	tree = tree.withAutoInputs()
+
+	if len(appArgs) > 1 && appArgs[1] == completeCommand {
+		tree.runComplete(appArgs)
+		return nil
 	}
 
-	fullCom, pathToCom, err := tree.FindCommand(appArgs)
+	fullCom, pathToCom, args, _, err := tree.findCommand(appArgs)
 
 	if err != nil {
 		return err
@@ -60,19 +136,30 @@ func Run(appArgs []string, tree *CommandTree) (err error) {
 	fullCom.ArgSets = append(fullCom.ArgSets, tree.Shared.ArgSets...)
 	fullCom.Opts = append(fullCom.Opts, tree.Shared.Opts...)
 
-	userCom, err := ParseArgs(appArgs, fullCom)
+	// Help lists the inherited inputs in their own section, so it is shown for the command
+	// before they are merged in.
+	helpCom := fullCom
+	tree.bindEnvVars(&helpCom, pathToCom)
+	inherited := tree.inheritedBy(fullCom, pathToCom)
+	fullCom.Flags = append(fullCom.Flags, inherited.Flags...)
+	fullCom.Opts = append(fullCom.Opts, inherited.Opts...)
+	tree.bindEnvVars(&fullCom, pathToCom)
+
+	userCom, err := parseCommandLine(args, fullCom, tree.parseSettings())
 
 	if err != nil {
 		return err
 	}
 
+	// Help is checked before the arguments are bound so that it is shown even when they
+	// match none of the usages of the command.
 	if tree.AutoHelp && !userCom.HideHelp {
-		if userCom.hasFlag(autoHelpFlag.ShortName) || userCom.hasFlag(autoHelpFlag.LongName) || userCom.hasArg(autoHelpArg.Value) {
+		if userCom.Bool(autoHelpFlag.LongName) || userCom.hasArg(autoHelpArg.Value) {
 			helpStr := ""
 			if tree.ToHelpString == nil {
-				helpStr = ToHelpString(fullCom, pathToCom)
+				helpStr = tree.HelpString(helpCom, pathToCom)
 			} else {
-				helpStr = tree.ToHelpString(fullCom, pathToCom)
+				helpStr = tree.ToHelpString(helpCom, pathToCom)
 			}
 
 			if helpStr != "" {
@@ -81,25 +168,84 @@ func Run(appArgs []string, tree *CommandTree) (err error) {
 			return nil
 		}
 	}
-	if tree.Shared.PreAction != nil {
-		tree.Shared.PreAction(userCom)
-		if err != nil {
-			return err
-		}
+
+	conf, err := tree.loadConfig(userCom)
+	if err != nil {
+		return err
 	}
-	if fullCom.Action != nil {
-		fullCom.Action(userCom)
-		if err != nil {
-			return err
-		}
+	err = resolveValues(fullCom, &userCom, conf, commandPath(pathToCom, fullCom.Name)[1:])
+	if err != nil {
+		return err
 	}
-	if tree.Shared.PostAction != nil {
-		tree.Shared.PostAction(userCom)
-		if err != nil {
-			return err
-		}
+
+	if tree.AutoConfig && userCom.Bool(autoShowConfigFlag.LongName) {
+		fmt.Println(ShowConfig(fullCom, userCom))
+		return nil
+	}
+
+	err = CheckRequired(fullCom, userCom)
+	if err != nil {
+		return err
+	}
+	err = CheckGroups(fullCom, userCom)
+	if err != nil {
+		return err
+	}
+
+	userCom.ctx = ctx
+	return tree.runActions(fullCom, userCom, pathToCom)
+}
+
+// withAutoInputs returns a copy of the tree with the inputs turned on by AutoHelp and
+// AutoConfig added to its shared parameters, leaving the tree itself as it is so that it
+// can be run again.
+func (tree *CommandTree) withAutoInputs() *CommandTree {
+	runTree := *tree
+	shared := &runTree.Shared
+	if tree.AutoHelp {
+		shared.Args = append(append([]Argument{}, shared.Args...), autoHelpArg)
+		shared.Flags = append(append([]Flag{}, shared.Flags...), autoHelpFlag)
+	}
+	if tree.AutoConfig {
+		shared.Opts = append(append([]Option{}, shared.Opts...), autoConfigOpt)
+		shared.Flags = append(append([]Flag{}, shared.Flags...), autoShowConfigFlag)
 	}
-	return nil
+	return &runTree
+}
+
+// ActionError is returned by Run when the PreAction, Action, or PostAction of a command
+// fails. Err is the error returned by the failing function.
+type ActionError struct {
+	Path  []string
+	Stage string
+	Err   error
+}
+
+func (e *ActionError) Error() string {
+	return fmt.Sprintf("cli: %s: %s failed: %s", strings.Join(e.Path, " "), e.Stage, e.Err.Error())
+}
+
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+func wrapActionError(comPath []string, stage string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ActionError{Path: comPath, Stage: stage, Err: err}
+}
+
+func (tree CommandTree) parseSettings() parseSettings {
+	return parseSettings{prefixMatching: tree.PrefixMatching}
+}
+
+// commandPath returns the full path to a command, from the root down to and including the
+// command itself.
+func commandPath(pathToCom []string, name string) (comPath []string) {
+	comPath = make([]string, 0, len(pathToCom)+1)
+	comPath = append(comPath, pathToCom...)
+	return append(comPath, name)
 }
 
 /*
@@ -107,11 +253,20 @@ func Run(appArgs []string, tree *CommandTree) (err error) {
 * options, and arguments of the command string.
  */
 func (tree CommandTree) FindCommand(appArgs []string) (fullCom Command, pathToCom []string, err error) {
+	fullCom, pathToCom, _, _, err = tree.findCommand(appArgs)
+	return fullCom, pathToCom, err
+}
+
+// findCommand does the work of FindCommand. It also returns appArgs rearranged for parsing:
+// the path to the command with the names of the commands in place of any aliases or
+// prefixes, then the persistent flags and options given in front of its subcommands, and
+// then the rest, which begins at args[rest].
+func (tree CommandTree) findCommand(appArgs []string) (fullCom Command, pathToCom []string, args []string, rest int, err error) {
 	numArgs := len(appArgs)
 
 	if numArgs == 0 {
 		err = errors.New("cli: No arguments provided")
-		return fullCom, pathToCom, err
+		return fullCom, pathToCom, args, rest, err
 	}
 
 	curCommand := &tree.Root
@@ -120,56 +275,167 @@ func (tree CommandTree) FindCommand(appArgs []string) (fullCom Command, pathToCo
 	if curCommand.Name != curArg {
 		errStr := fmt.Sprintf("cli: Command %s not found", curCommand.Name)
 		err = errors.New(errStr)
-		return fullCom, pathToCom, err
+		return fullCom, pathToCom, args, rest, err
 	}
 
 	pathToCom = append(pathToCom, curArg)
 
-	predBegin := 0
+	// Persistent inputs of the commands found so far may come before a subcommand. They are
+	// moved after the path once the subcommand is found.
+	var scope Command
+	scope.addPersistent(*curCommand)
+	var moved, pending []string
+	predBegin := 1
 
-	for i := 1; i < numArgs; i++ {
-		predBegin++
+	i := 1
+	for ; i < numArgs; i++ {
 		curArg = appArgs[i]
 		argFound := false
 
 		if strings.HasPrefix(curArg, "-") {
-			break
-		}
-
-		subCount := len(curCommand.SubCommands)
-		for j := 0; j < subCount; j++ {
-			curSub := &curCommand.SubCommands[j]
-
-			if curArg == curSub.Name {
-				curCommand = curSub
-				argFound = true
-				pathToCom = append(pathToCom, curArg)
+			tokens := appArgs[i:]
+			if tree.PrefixMatching {
+				token, err := scope.expandToken(curArg)
+				if err != nil {
+					return fullCom, pathToCom, args, rest, err
+				}
+				tokens = append([]string{token}, appArgs[i+1:]...)
+			}
+			n := scope.inputTokens(tokens)
+			if n == 0 {
 				break
 			}
+			pending = append(pending, tokens[:n]...)
+			i += n - 1
+			continue
+		}
+
+		curSub, err := tree.findSubCommand(curCommand, curArg, pathToCom)
+		if err != nil {
+			return fullCom, pathToCom, args, rest, err
+		}
+		if curSub != nil {
+			curCommand = curSub
+			argFound = true
+			pathToCom = append(pathToCom, curSub.Name)
+			scope.addPersistent(*curSub)
+			moved = append(moved, pending...)
+			pending = nil
+			predBegin = i + 1
 		}
 
 		if argFound == false {
+			err = tree.checkUnknownCommand(curCommand, curArg, pathToCom)
+			if err != nil {
+				return fullCom, pathToCom, args, rest, err
+			}
 			break
 		}
 	}
+
+	// Persistent inputs at the very end may still be followed by a subcommand
+	if i >= numArgs {
+		moved = append(moved, pending...)
+		predBegin = numArgs
+	}
+	args = append(append([]string{}, pathToCom...), moved...)
+	rest = len(args)
+	args = append(args, appArgs[predBegin:]...)
+
 	if pathToCom != nil {
 		pathToCom = pathToCom[:len(pathToCom)-1]
 	}
 
 	fullCom = *curCommand
 
-	return fullCom, pathToCom, err
+	return fullCom, pathToCom, args, rest, err
+}
+
+// ParseArgs checks appArgs against the definition c and returns the Command the user gave.
+// Inputs left off the command line are filled from the environment and from defaults.
+func ParseArgs(appArgs []string, c Command) (userCom Command, err error) {
+	userCom, err = parseCommandLine(appArgs, c, parseSettings{})
+	if err != nil {
+		return userCom, err
+	}
+	err = resolveValues(c, &userCom, nil, nil)
+	return userCom, err
+}
+
+// findSubCommand returns the subcommand of com that is named or aliased by arg. When the
+// tree has PrefixMatching set, a prefix of a single subcommand's name or alias also matches
+// it. It returns nil when nothing matches.
+func (tree CommandTree) findSubCommand(com *Command, arg string, pathToCom []string) (sub *Command, err error) {
+	for i := range com.SubCommands {
+		if com.SubCommands[i].isNamed(arg) {
+			return &com.SubCommands[i], nil
+		}
+	}
+	if !tree.PrefixMatching || arg == "" {
+		return nil, nil
+	}
+
+	var candidates []string
+	for i := range com.SubCommands {
+		for _, name := range append([]string{com.SubCommands[i].Name}, com.SubCommands[i].Aliases...) {
+			if strings.HasPrefix(name, arg) {
+				candidates = append(candidates, com.SubCommands[i].Name)
+				sub = &com.SubCommands[i]
+				break
+			}
+		}
+	}
+	if len(candidates) > 1 {
+		errStr := fmt.Sprintf("cli: ambiguous command '%s' for '%s', could be '%s'", arg, strings.Join(pathToCom, " "), strings.Join(candidates, "', '"))
+		return nil, errors.New(errStr)
+	}
+	return sub, nil
+}
+
+// checkUnknownCommand decides whether arg, found where a subcommand of com could be, is a
+// mistyped subcommand rather than an argument. It only is when com has subcommands but
+// takes no arguments, in which case the error suggests the subcommands closest to arg.
+func (tree CommandTree) checkUnknownCommand(com *Command, arg string, pathToCom []string) error {
+	if com.SubCommands == nil || com.hasArg(arg) || (Command{Args: tree.Shared.Args}).hasArg(arg) {
+		return nil
+	}
+	if com.positionalArgs() != nil || com.ArgSets != nil || (Command{Args: tree.Shared.Args}).positionalArgs() != nil {
+		return nil
+	}
+
+	var matches []string
+	if !tree.DisableSuggestions {
+		distance := tree.SuggestionDistance
+		if distance == 0 {
+			distance = defaultSuggestionDistance
+		}
+		var names []string
+		for _, sub := range com.SubCommands {
+			names = append(append(names, sub.Name), sub.Aliases...)
+		}
+		matches = suggestions(arg, names, distance)
+	}
+
+	errStr := fmt.Sprintf("cli: unknown command '%s' for '%s'%s", arg, strings.Join(pathToCom, " "), didYouMean(matches, singleQuote))
+	return errors.New(errStr)
+}
+
+// parseSettings carries the settings of a CommandTree that change how the command line is
+// parsed. ParseArgs uses the zero value.
+type parseSettings struct {
+	prefixMatching bool
 }
 
 // Big ugly function that does the grunt work of the program. It could be split into functions, but as it is
 // they would require a bunch or parameters some of them being pointers and would be just as ugly.
-func ParseArgs(appArgs []string, c Command) (userCom Command, err error) {
+func parseCommandLine(appArgs []string, c Command, settings parseSettings) (userCom Command, err error) {
 	predicateStart := 0
 	for i, arg := range appArgs {
-		if arg == c.Name {
+		if c.isNamed(arg) {
 			userCom.Name = c.Name
 			userCom.Usage = c.Usage
 			userCom.Description = c.Description
+			userCom.def = &c
 			predicateStart = i + 1
 			break
 		}
@@ -179,124 +445,141 @@ func ParseArgs(appArgs []string, c Command) (userCom Command, err error) {
 		return userCom, errors.New(errStr)
 	}
 	predicate := appArgs[predicateStart:]
-	predLen := len(predicate)
-	for i := 0; i < predLen; i++ {
+
+	// Tokens follow the getopt_long conventions. "--" ends the flags and options, after which
+	// every token is an Argument, and a lone "-" is an Argument, usually meaning stdin.
+	endOfInputs := false
+	for i := 0; i < len(predicate); i++ {
 		argStr := predicate[i]
 
 		if argStr == " " || argStr == "" {
 			continue
 		}
 
-		// Must be a 1 piece Option. We split it into a 2 part form and process it as if the user
-		// had typed --key value rather than --key=value
-		if strings.Contains(argStr, "=") {
-			keyValue := strings.Split(argStr, "=")
-			predicate[i] = keyValue[1]
-
-			//insert value at i
-			predicate = append(predicate, "")
-			copy(predicate[i+1:], predicate[i:])
-			predicate[i] = keyValue[0]
-
-			// must redo controlling variables after doind an insert on predicate
-			argStr = predicate[i]
-			predLen = len(predicate)
-		}
-
-		if strings.HasPrefix(argStr, "--") {
-			i, err = parseLongForm(predicate, i, c, &userCom)
-
-		} else if strings.HasPrefix(argStr, "-") {
-			i, err = parseShortForm(predicate, i, c, &userCom)
-
-			// Must be an Argument
-		} else {
+		if endOfInputs || argStr == "-" || !strings.HasPrefix(argStr, "-") {
 			a := Argument{Value: argStr}
 			userCom.Args = append(userCom.Args, a)
+		} else if argStr == "--" {
+			endOfInputs = true
+		} else if strings.HasPrefix(argStr, "--") {
+			i, err = parseLongForm(predicate, i, c, &userCom, settings)
+		} else {
+			i, err = parseShortForm(predicate, i, c, &userCom)
 		}
 
 		if err != nil {
 			return userCom, err
 		}
 	}
+
 	return userCom, nil // nil error
 }
 
-func parseLongForm(predicate []string, pos int, c Command, userCom *Command) (newPos int, err error) {
+// parseLongForm parses "--name", "--name value", or "--name=value" at predicate[pos]. Only
+// the first "=" separates the name from the value, so "--filter=a=b" has the value "a=b".
+// Flags take an explicit value of true or false, and Negatable flags also "--no-name".
+func parseLongForm(predicate []string, pos int, c Command, userCom *Command, settings parseSettings) (newPos int, err error) {
 	// strip off "--"
 	argStr := predicate[pos][2:]
-	predLen := len(predicate)
+	value, hasValue := "", false
+	if eq := strings.Index(argStr, "="); eq >= 0 {
+		argStr, value, hasValue = argStr[:eq], argStr[eq+1:], true
+	}
+
+	if settings.prefixMatching {
+		argStr, err = c.expandLongName(argStr)
+		if err != nil {
+			return newPos, err
+		}
+	}
 
-	if c.hasFlag(argStr) {
-		if !userCom.hasFlag(argStr) {
-			f := Flag{LongName: argStr}
-			userCom.Flags = append(userCom.Flags, f)
+	if def, found := c.findFlag(argStr); found {
+		on := true
+		if hasValue {
+			on, err = strconv.ParseBool(value)
+			if err != nil {
+				errStr := fmt.Sprintf("cli: Invalid value %q for flag --%s: expected true or false", value, argStr)
+				return newPos, errors.New(errStr)
+			}
+		}
+		addFlag(userCom, def, Flag{LongName: argStr, Negated: !on})
+	} else if def, found := c.findNegatedFlag(argStr); found {
+		if hasValue {
+			errStr := fmt.Sprintf("cli: Flag --%s does not take a value", argStr)
+			return newPos, errors.New(errStr)
 		}
-	} else if c.hasOption(argStr) {
-		if !userCom.hasOption(argStr) {
-			if pos+1 >= predLen {
-				return // fix error later option specified with no value
+		addFlag(userCom, def, Flag{LongName: def.LongName, Negated: true})
+	} else if def, found := c.findOption(argStr); found {
+		if !hasValue {
+			if pos+1 >= len(predicate) {
+				errStr := fmt.Sprintf("cli: No value provided for option --%s", argStr)
+				return newPos, errors.New(errStr)
 			}
-			o := Option{LongName: argStr, Value: predicate[pos+1]}
-			userCom.Opts = append(userCom.Opts, o)
-			//If the arg was an option we need to move the iterator an extra position
+			//If the value is the next arg we need to move the iterator an extra position
+			pos++
+			value = predicate[pos]
+		}
+		err = addOption(userCom, def, Option{LongName: argStr, Value: value})
+		if err != nil {
+			return newPos, err
 		}
-		pos++
 	} else {
-		errStr := fmt.Sprintf("cli: Long form input --%s not found", argStr)
+		matches := nearest(argStr, c.longNames(), defaultSuggestionDistance)
+		errStr := fmt.Sprintf("cli: Long form input --%s not found%s", argStr, didYouMean(matches, longFormQuote))
 		return newPos, errors.New(errStr)
 	}
 	newPos = pos
 	return newPos, nil
 }
 
+// parseShortForm parses a cluster of short flags such as "-xvf" at predicate[pos]. The
+// cluster may end with an option, which takes the rest of the token as its value, as in
+// "-ofile" or "-o=file", or the next token when nothing is left, as in "-xvf file".
 func parseShortForm(predicate []string, pos int, c Command, userCom *Command) (newPos int, err error) {
 	// strip off "-"
 	argStr := predicate[pos][1:]
-	predLen := len(predicate)
-
-	// For the case of multiple flags like "tar -xvf"
-	if len(argStr) > 1 {
-		for _, charUtf := range argStr {
-			char := string(charUtf)
-			if c.hasFlag(char) {
-				if !userCom.hasFlag(char) {
-					f := Flag{ShortName: char}
-					userCom.Flags = append(userCom.Flags, f)
+
+	prev := ""
+	for j, charUtf := range argStr {
+		char := string(charUtf)
+		if def, found := c.findFlag(char); found {
+			addFlag(userCom, def, Flag{ShortName: char})
+			prev = char
+			continue
+		}
+
+		if def, found := c.findOption(char); found {
+			value := argStr[j+len(char):]
+			if value == "" {
+				if pos+1 >= len(predicate) {
+					errStr := fmt.Sprintf("cli: No value provided for option -%s", char)
+					return newPos, errors.New(errStr)
 				}
+				//If the value is the next arg we need to move the iterator an extra position
+				pos++
+				value = predicate[pos]
 			} else {
-				errStr := fmt.Sprintf("cli: Short form input -%s is too Long", argStr)
-				err = errors.New(errStr)
+				value = strings.TrimPrefix(value, "=")
+			}
+			err = addOption(userCom, def, Option{ShortName: char, Value: value})
+			if err != nil {
 				return newPos, err
 			}
+			return pos, nil
 		}
-	} else {
-		if c.hasFlag(argStr) {
-			if !userCom.hasFlag(argStr) {
-				f := Flag{ShortName: argStr}
-				userCom.Flags = append(userCom.Flags, f)
-			}
-		} else if c.hasOption(argStr) {
 
-			if !userCom.hasOption(argStr) {
-				if pos+1 >= predLen {
-					errStr := fmt.Sprintf("cli: No value provided for option -%s", argStr)
-					err = errors.New(errStr)
-					return newPos, err
-				}
-				o := Option{ShortName: argStr, Value: predicate[pos+1]}
-				userCom.Opts = append(userCom.Opts, o)
-				//If the arg was an option we need to move the iterator an extra position
-			}
-			pos++
+		var errStr string
+		if char == "=" && prev != "" {
+			errStr = fmt.Sprintf("cli: Flag -%s does not take a value", prev)
+		} else if len(argStr) > 1 {
+			errStr = fmt.Sprintf("cli: Short form input -%s not found in -%s", char, argStr)
 		} else {
-			errStr := fmt.Sprintf("cli: Short form input -%s not found", argStr)
-			err = errors.New(errStr)
-			return newPos, err
+			errStr = fmt.Sprintf("cli: Short form input -%s not found", argStr)
 		}
+		return newPos, errors.New(errStr)
 	}
 	newPos = pos
-	return newPos, err
+	return newPos, nil
 }
 
 type Node struct {
@@ -328,7 +611,8 @@ func PrintTreeHelp(c *Command) {
 }
 
 func addChildrenToSlice(n *Node, slice *[]Node) {
-	pathToNode := append(n.PathToCom, n.Name)
+	// Copy the path so that siblings do not share, and overwrite, the same backing array
+	pathToNode := commandPath(n.PathToCom, n.Name)
 	subCount := len(n.SubCommands)
 	for i := 0; i < subCount; i++ {
 		child := Node{n.SubCommands[i], n.Level + 1, pathToNode} // pointer to a command
@@ -1,9 +1,19 @@
 package cli
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestPrintCommand(t *testing.T) {
@@ -22,6 +32,91 @@ func TestDefaultHelpTemplate(t *testing.T) {
 	ToHelpString(comTree.Root, nil)
 }
 
+func TestHelpTemplates(t *testing.T) {
+	tempTree := NewCommandTree()
+	tempTree.Author = "Jeff Williams"
+	tempTree.Root = Command{
+		Name:        "the",
+		Description: "a long description that needs to be wrapped",
+		SubCommands: []Command{{
+			Name:         "quick",
+			HelpTemplate: "{{.Path}} only",
+		}},
+	}
+	tempTree.Shared.Flags = []Flag{{LongName: "verbose"}}
+
+	help := tempTree.HelpString(tempTree.Root, nil)
+	if help != ToHelpString(tempTree.Root, nil) {
+		t.Errorf("Tree without a template did not render the default help:\n%s", help)
+	}
+
+	tempTree.HelpTemplate = `{{.Command.Name}} by {{.Tree.Author}}
+{{indent 2 (wrap 20 .Command.Description)}}
+{{columnize .Width (flagRows .Shared.Flags)}}`
+	help = tempTree.HelpString(tempTree.Root, nil)
+	expected := "the by Jeff Williams\n  a long description\n  that needs to be\n  wrapped\n    --verbose,  "
+	if help != expected {
+		t.Errorf("Tree template rendered %q, expected %q", help, expected)
+	}
+
+	help = tempTree.HelpString(tempTree.Root.SubCommands[0], []string{"the"})
+	if help != "the quick only" {
+		t.Errorf("Command template was not used: %q", help)
+	}
+}
+
+func TestHelpWrapping(t *testing.T) {
+	defer func(width func() int) { TerminalWidth = width }(TerminalWidth)
+	TerminalWidth = func() int { return 40 }
+
+	c := Command{
+		Name:        "brown",
+		Description: "short",
+		Opts: []Option{{
+			ShortName:   "f",
+			LongName:    "LongF",
+			Description: "a description that is much too long to fit on one line of the terminal",
+		}},
+	}
+	expected := ` Options:
+  -f  --LongF,  a description that is
+                much too long to fit on
+                one line of the terminal
+`
+	if help := ToHelpString(c, nil); !strings.Contains(help, expected) {
+		t.Errorf("Help was not wrapped to the terminal:\n%s", help)
+	}
+
+	defer func(width func() int, columns string) {
+		stdoutWidth = width
+		os.Setenv("COLUMNS", columns)
+	}(stdoutWidth, os.Getenv("COLUMNS"))
+	tty := 0
+	stdoutWidth = func() int { return tty }
+	for _, test := range []struct {
+		tty      int
+		columns  string
+		expected int
+	}{
+		{100, "120", 100},
+		{0, "120", 120},
+		{0, "wide", 80},
+		{0, "", 80},
+	} {
+		tty = test.tty
+		os.Setenv("COLUMNS", test.columns)
+		if width := terminalWidth(); width != test.expected {
+			t.Errorf("Expected a width of %d with a terminal of %d and COLUMNS=%q, got %d", test.expected, test.tty, test.columns, width)
+		}
+	}
+
+	// Tables wrap to the Width given to the template
+	help := renderHelp(`{{columnize .Width (optionRows .Command.Opts)}}`, HelpData{Command: c, Width: 60})
+	if !strings.Contains(help, "  -f  --LongF,  a description that is much too long to fit\n") {
+		t.Errorf("Table was not wrapped to the Width of the template:\n%s", help)
+	}
+}
+
 func TestFind(t *testing.T) {
 	findHelper(t, "the", &comTree.Root)
 	findHelper(t, "the quick", Quick)
@@ -32,12 +127,200 @@ func TestFind(t *testing.T) {
 	findHelper(t, "the quick red", QuickRed)
 }
 
+func TestSuggestions(t *testing.T) {
+	// Words in the place of a subcommand are arguments when the command takes them
+	for _, line := range []string{"the quick bronw fox", "the quick b", "the quick brown zebra"} {
+		if _, _, err := comTree.FindCommand(strings.Split(line, " ")); err != nil {
+			t.Errorf("%s: %s", line, err)
+		}
+	}
+	findHelper(t, "the quick brown fox", QuickBrownFox)
+
+	var userCom Command
+	tempTree := NewCommandTree()
+	tempTree.Root = Command{
+		Name: "the",
+		Args: []Argument{{Name: "n"}},
+		Action: func(c Command) error {
+			userCom = c
+			return nil
+		},
+		SubCommands: []Command{{Name: "rm"}, {Name: "list", Aliases: []string{"ls"}}},
+	}
+	for _, n := range []string{"42", "x", "l"} {
+		userCom = Command{}
+		if err := Run([]string{"the", n}, &tempTree); err != nil {
+			t.Errorf("the %s: %s", n, err)
+		} else if userCom.Arg("n") != n {
+			t.Errorf("the %s: expected <n> to be %s, got %s", n, n, userCom.String())
+		}
+	}
+
+	tempTree = NewCommandTree()
+	tempTree.Root = Command{Name: "the", SubCommands: []Command{{Name: "deploy"}, {Name: "delete"}}}
+	_, _, err := tempTree.FindCommand(strings.Split("the delpoy", " "))
+	expected := "cli: unknown command 'delpoy' for 'the'; did you mean 'deploy'?"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+	_, _, err = tempTree.FindCommand(strings.Split("the zebra", " "))
+	if err == nil || err.Error() != "cli: unknown command 'zebra' for 'the'" {
+		t.Errorf("Expected an unknown command error, got %v", err)
+	}
+	err = Run(strings.Split("the delpoy", " "), &tempTree)
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q from Run with AutoHelp, got %v", expected, err)
+	}
+	_, _, err = tempTree.FindCommand(strings.Split("the dele", " "))
+	if err == nil || !strings.HasSuffix(err.Error(), "did you mean 'delete'?") {
+		t.Errorf("Expected a suggestion for dele, got %v", err)
+	}
+	tempTree.DisableSuggestions = true
+	_, _, err = tempTree.FindCommand(strings.Split("the deplyo", " "))
+	if err == nil || strings.Contains(err.Error(), "did you mean") {
+		t.Errorf("Expected an error without suggestions, got %v", err)
+	}
+
+	// At the same distance, names that start with what was typed come first
+	if matches := suggestions("stat", []string{"stash", "status"}, 2); strings.Join(matches, " ") != "status stash" {
+		t.Errorf("Expected status before stash, got %v", matches)
+	}
+
+	_, err = ParseArgs(strings.Split("the quick brown --LongFF val", " "), *QuickBrown)
+	if err == nil || !strings.HasSuffix(err.Error(), "did you mean --LongF?") {
+		t.Errorf("Expected a suggestion for --LongFF, got %v", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	var userCom Command
+	action := func(c Command) error {
+		userCom = c
+		return nil
+	}
+	tempTree := NewCommandTree()
+	tempTree.ToHelpString = nil
+	tempTree.Root = Command{
+		Name: "the",
+		SubCommands: []Command{
+			{
+				Name:    "deploy",
+				Aliases: []string{"dep"},
+				Flags:   []Flag{{LongName: "verbose"}, {LongName: "version"}},
+				Opts:    []Option{{LongName: "target"}},
+				Action:  action,
+			},
+			{Name: "remove", Aliases: []string{"rm"}, Action: action},
+			{Name: "delete", Action: action},
+		},
+	}
+
+	for _, line := range []string{"the deploy", "the dep", "the rm"} {
+		fullCom, pathToCom, err := tempTree.FindCommand(strings.Split(line, " "))
+		if err != nil || strSliceCmp(pathToCom, []string{"the"}) != true || fullCom.Name == "" {
+			t.Errorf("FindCommand(%q) found %q %v %v", line, fullCom.Name, pathToCom, err)
+		}
+	}
+	if err := Run(strings.Split("the dep --target x", " "), &tempTree); err != nil || userCom.Name != "deploy" || !userCom.hasOption("target") {
+		t.Errorf("Run with an alias failed: %v %s", err, userCom.String())
+	}
+
+	if err := Run(strings.Split("the depl", " "), &tempTree); err == nil {
+		t.Errorf("Prefix matched without PrefixMatching")
+	}
+	tempTree.PrefixMatching = true
+	if err := Run(strings.Split("the depl --tar x --verb", " "), &tempTree); err != nil || userCom.Name != "deploy" || !userCom.hasOption("target") || !userCom.hasFlag("verbose") {
+		t.Errorf("Run with prefixes failed: %v %s", err, userCom.String())
+	}
+	err := Run(strings.Split("the de", " "), &tempTree)
+	if err == nil || err.Error() != "cli: ambiguous command 'de' for 'the', could be 'deploy', 'delete'" {
+		t.Errorf("Expected an ambiguous command error, got %v", err)
+	}
+	err = Run(strings.Split("the deploy --ver", " "), &tempTree)
+	if err == nil || err.Error() != "cli: ambiguous input --ver, could be --verbose, --version" {
+		t.Errorf("Expected an ambiguous input error, got %v", err)
+	}
+
+	help := ToHelpString(tempTree.Root, nil)
+	if !strings.Contains(help, "deploy (dep):") || !strings.Contains(help, "remove (rm):") {
+		t.Errorf("Aliases missing from help:\n%s", help)
+	}
+}
+
+func TestPersistent(t *testing.T) {
+	var userCom Command
+	action := func(c Command) error {
+		userCom = c
+		return nil
+	}
+	tempTree := NewCommandTree()
+	tempTree.ToHelpString = nil
+	tempTree.Root = Command{
+		Name:  "the",
+		Flags: []Flag{{ShortName: "v", LongName: "verbose", Persistent: true}, {LongName: "local"}},
+		SubCommands: []Command{{
+			Name: "db",
+			Opts: []Option{{ShortName: "d", LongName: "database", Persistent: true}},
+			SubCommands: []Command{{
+				Name:   "migrate",
+				Flags:  []Flag{{LongName: "dry-run"}},
+				Args:   []Argument{{Name: "version"}},
+				Action: action,
+			}},
+		}},
+	}
+
+	for _, line := range []string{
+		"the db migrate -v -d prod 42",
+		"the --verbose db migrate --database=prod 42",
+		"the -v db -d prod migrate 42",
+		"the db -vd prod migrate 42",
+	} {
+		userCom = Command{}
+		if err := Run(strings.Split(line, " "), &tempTree); err != nil {
+			t.Errorf("%s: %s", line, err)
+			continue
+		}
+		if userCom.Name != "migrate" || !userCom.Bool("verbose") || userCom.Value("database") != "prod" || userCom.Arg("version") != "42" {
+			t.Errorf("%s: parsed %s", line, userCom.String())
+		}
+	}
+
+	for _, line := range []string{"the --local db migrate", "the db --dry-run migrate"} {
+		if err := Run(strings.Split(line, " "), &tempTree); err == nil {
+			t.Errorf("%s: expected a non persistent input to be rejected", line)
+		}
+	}
+
+	tempTree.PrefixMatching = true
+	for _, line := range []string{"the --verb db --data=prod migrate 42", "the db migrate --verb --data prod 42"} {
+		userCom = Command{}
+		if err := Run(strings.Split(line, " "), &tempTree); err != nil {
+			t.Errorf("%s: %s", line, err)
+			continue
+		}
+		if !userCom.Bool("verbose") || userCom.Value("database") != "prod" || userCom.Arg("version") != "42" {
+			t.Errorf("%s: parsed %s", line, userCom.String())
+		}
+	}
+	tempTree.PrefixMatching = false
+
+	migrate := tempTree.Root.SubCommands[0].SubCommands[0]
+	help := tempTree.HelpString(migrate, []string{"the", "db"})
+	if !strings.Contains(help, " Flags:\n    --dry-run,  \n") || !strings.Contains(help, " Inherited options:\n  -v  --verbose,   \n  -d  --database,  \n") {
+		t.Errorf("Inherited options missing from help:\n%s", help)
+	}
+
+	completeHelper(t, tempTree, "the -v db -d prod m", "migrate", CompleteNoFiles)
+}
+
 func TestParsingArguments(t *testing.T) {
 	var expected1 Command = Command{
 		Name:        "brown",
 		Description: "the quick brown",
 		Usage:       "use a brown?",
 		Args: []Argument{{
+			Name:  "Arg1",
 			Value: "fox",
 		}},
 	}
@@ -49,9 +332,11 @@ func TestParsingArguments(t *testing.T) {
 		Usage:       "use a brown?",
 		Args: []Argument{
 			{
+				Name:  "Arg1",
 				Value: "fox",
 			},
 			{
+				Name:  "Arg2",
 				Value: "dog",
 			},
 		},
@@ -133,6 +418,7 @@ func TestParsingAll(t *testing.T) {
 		Description: "the quick brown",
 		Usage:       "use a brown?",
 		Args: []Argument{{
+			Name:  "Arg1",
 			Value: "fox",
 		}},
 		Opts: []Option{
@@ -157,6 +443,67 @@ func TestParsingAll(t *testing.T) {
 	parseHelper(t, "the quick brown fox -g val --LongF val2 --LongD -c", *QuickBrown, expected1)
 }
 
+func TestTypedValues(t *testing.T) {
+	typedCom := Command{
+		Name: "brown",
+		Opts: []Option{
+			{LongName: "count", ShortName: "c", Type: IntType},
+			{LongName: "timeout", Type: DurationType},
+			{LongName: "tags", Type: StringSliceType},
+			{LongName: "labels", Type: MapType},
+			{LongName: "ratio", Type: FloatType},
+			{LongName: "name"},
+		},
+		Args: []Argument{{Name: "enabled", Type: BoolType}},
+	}
+
+	userCom, err := ParseArgs(strings.Split("the quick brown -c 3 --timeout 1m --tags a,b --labels k=v --ratio 0.5 --name x true", " "), typedCom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userCom.Opts[0].TypedValue != 3 {
+		t.Errorf("Expected int 3, got %#v", userCom.Opts[0].TypedValue)
+	}
+	if userCom.Opts[1].TypedValue != time.Minute {
+		t.Errorf("Expected 1m, got %#v", userCom.Opts[1].TypedValue)
+	}
+	if tags := userCom.Opts[2].TypedValue.([]string); len(tags) != 2 || tags[1] != "b" {
+		t.Errorf("Expected [a b], got %#v", tags)
+	}
+	if labels := userCom.Opts[3].TypedValue.(map[string]string); labels["k"] != "v" {
+		t.Errorf("Expected map[k:v], got %#v", labels)
+	}
+	if userCom.Opts[4].TypedValue != 0.5 {
+		t.Errorf("Expected 0.5, got %#v", userCom.Opts[4].TypedValue)
+	}
+	if userCom.Opts[5].TypedValue != "x" {
+		t.Errorf("Expected string x, got %#v", userCom.Opts[5].TypedValue)
+	}
+	if userCom.Args[0].TypedValue != true {
+		t.Errorf("Expected bool true, got %#v", userCom.Args[0].TypedValue)
+	}
+
+	_, err = ParseArgs(strings.Split("the quick brown -c three", " "), typedCom)
+	if err == nil || !strings.Contains(err.Error(), "--count") {
+		t.Errorf("Expected an error naming --count, got %v", err)
+	}
+
+	userCom, err = ParseArgs(strings.Split("the quick brown -c 010", " "), typedCom)
+	if err != nil || userCom.Opts[0].TypedValue != 10 {
+		t.Errorf("Expected 010 to be read as 10, got %#v %v", userCom.Opts[0].TypedValue, err)
+	}
+	assertParseFails(t, "the quick brown -c 0x10", typedCom)
+
+	upper := ParserFunc(func(str string) (interface{}, error) {
+		return strings.ToUpper(str), nil
+	})
+	customCom := Command{Name: "brown", Opts: []Option{{LongName: "shout", Type: upper}}}
+	userCom, err = ParseArgs(strings.Split("the quick brown --shout hi", " "), customCom)
+	if err != nil || userCom.Opts[0].TypedValue != "HI" {
+		t.Errorf("Custom parser was not applied: %v %#v", err, userCom.Opts)
+	}
+}
+
 func TestRunActions(t *testing.T) {
 	ResetActionTesters()
 	assertActions(t, false)
@@ -164,6 +511,785 @@ func TestRunActions(t *testing.T) {
 	assertActions(t, true)
 }
 
+func TestActionErrors(t *testing.T) {
+	actionErr := errors.New("action failed")
+	var postErr error
+	postRan := false
+
+	tempTree := NewCommandTree()
+	tempTree.Root = Command{
+		Name: "the",
+		SubCommands: []Command{{
+			Name: "quick",
+			Action: func(c Command) error {
+				return actionErr
+			},
+		}},
+	}
+	tempTree.Shared.PostAction = func(c Command) error {
+		postRan = true
+		postErr = c.Err
+		return nil
+	}
+
+	err := Run(strings.Split("the quick", " "), &tempTree)
+	aErr, ok := err.(*ActionError)
+	if !ok {
+		t.Fatalf("Run returned %v, expected an *ActionError", err)
+	}
+	if aErr.Err != actionErr || aErr.Stage != "Action" || strings.Join(aErr.Path, " ") != "the quick" {
+		t.Errorf("Unexpected ActionError: %s", aErr.Error())
+	}
+	if !postRan || postErr != err {
+		t.Errorf("PostAction did not see the Action error")
+	}
+
+	actionRan := false
+	tempTree.Root.SubCommands[0].Action = func(c Command) error {
+		actionRan = true
+		return nil
+	}
+	tempTree.Shared.PreAction = func(c Command) error {
+		return actionErr
+	}
+	postRan = false
+	err = Run(strings.Split("the quick", " "), &tempTree)
+	if aErr, ok := err.(*ActionError); !ok || aErr.Stage != "PreAction" {
+		t.Errorf("Run returned %v, expected a PreAction error", err)
+	}
+	if actionRan {
+		t.Errorf("Action ran after PreAction failed")
+	}
+	if !postRan {
+		t.Errorf("PostAction did not run after PreAction failed")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var calls []string
+	hook := func(name string, err error) ActionFunc {
+		return func(c Command) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	wrap := func(name string) Middleware {
+		return func(next ActionFunc) ActionFunc {
+			return func(c Command) error {
+				calls = append(calls, name+" before")
+				err := next(c)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+
+	tempTree := NewCommandTree()
+	tempTree.Shared.PreAction = hook("shared pre", nil)
+	tempTree.Shared.PostAction = hook("shared post", nil)
+	tempTree.Shared.Middleware = []Middleware{wrap("shared")}
+	tempTree.Root = Command{
+		Name:       "the",
+		PreAction:  hook("the pre", nil),
+		PostAction: hook("the post", nil),
+		SubCommands: []Command{{
+			Name:       "db",
+			PreAction:  hook("db pre", nil),
+			PostAction: hook("db post", nil),
+			Middleware: []Middleware{wrap("db")},
+			SubCommands: []Command{{
+				Name:       "migrate",
+				PreAction:  hook("migrate pre", nil),
+				PostAction: hook("migrate post", nil),
+				Action:     hook("migrate", nil),
+			}},
+		}},
+	}
+
+	if err := Run(strings.Split("the db migrate", " "), &tempTree); err != nil {
+		t.Error(err)
+	}
+	expected := "shared pre, the pre, db pre, migrate pre, shared before, db before, migrate, db after, shared after, " +
+		"migrate post, db post, the post, shared post"
+	if strings.Join(calls, ", ") != expected {
+		t.Errorf("Expected %s\ngot %s", expected, strings.Join(calls, ", "))
+	}
+
+	calls = nil
+	tempTree.Root.SubCommands[0].PreAction = hook("db pre", errors.New("no connection"))
+	err := Run(strings.Split("the db migrate", " "), &tempTree)
+	if aErr, ok := err.(*ActionError); !ok || aErr.Stage != "PreAction" {
+		t.Errorf("Run returned %v, expected a PreAction error", err)
+	}
+	expected = "shared pre, the pre, db pre, db post, the post, shared post"
+	if strings.Join(calls, ", ") != expected {
+		t.Errorf("Expected %s\ngot %s", expected, strings.Join(calls, ", "))
+	}
+
+	calls = nil
+	tempTree.Root.SubCommands[0].PreAction = nil
+	tempTree.Root.SubCommands[0].Middleware = []Middleware{func(next ActionFunc) ActionFunc {
+		return func(c Command) error {
+			return errors.New("not allowed")
+		}
+	}}
+	err = Run(strings.Split("the db migrate", " "), &tempTree)
+	if aErr, ok := err.(*ActionError); !ok || aErr.Stage != "Action" || aErr.Err.Error() != "not allowed" {
+		t.Errorf("Run returned %v, expected the Middleware error", err)
+	}
+	for _, call := range calls {
+		if call == "migrate" {
+			t.Errorf("Action ran when the Middleware stopped it")
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	var signals chan<- os.Signal
+	notified := false
+	defer func(notify func(chan<- os.Signal), stop func(chan<- os.Signal)) {
+		notifySignals, stopSignals = notify, stop
+	}(notifySignals, stopSignals)
+	notifySignals = func(c chan<- os.Signal) {
+		notified = true
+		signals = c
+	}
+	stopSignals = func(c chan<- os.Signal) {}
+
+	type ctxKey struct{}
+	var preValue interface{}
+	var action ContextActionFunc
+	tempTree := NewCommandTree()
+	tempTree.Root = Command{
+		Name: "the",
+		PreAction: func(c Command) error {
+			preValue = c.Context().Value(ctxKey{})
+			return nil
+		},
+		ContextAction: func(ctx context.Context, c Command) error {
+			return action(ctx, c)
+		},
+	}
+
+	action = func(ctx context.Context, c Command) error {
+		if ctx.Value(ctxKey{}) != "value" {
+			t.Errorf("ContextAction was not given the context passed to RunContext")
+		}
+		return nil
+	}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	if err := RunContext(ctx, []string{"the"}, &tempTree); err != nil {
+		t.Error(err)
+	}
+	if preValue != "value" {
+		t.Errorf("PreAction was not given the context passed to RunContext")
+	}
+
+	notified = false
+	action = func(ctx context.Context, c Command) error {
+		if ctx != context.Background() {
+			t.Errorf("Run gave the ContextAction a context other than context.Background()")
+		}
+		return nil
+	}
+	if err := Run([]string{"the"}, &tempTree); err != nil || notified {
+		t.Errorf("Run installed signal handling or failed: %v", err)
+	}
+
+	// The first signal cancels the context
+	action = func(ctx context.Context, c Command) error {
+		signals <- os.Interrupt
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+	err := RunContext(context.Background(), []string{"the"}, &tempTree)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the context to be cancelled, got %v", err)
+	}
+
+	// A second signal, or the end of the grace period, exits
+	exitCodes := make(chan int, 1)
+	tempTree.Exit = func(code int) {
+		exitCodes <- code
+	}
+	waitForExit := func(ctx context.Context, c Command) error {
+		select {
+		case code := <-exitCodes:
+			return fmt.Errorf("exit %d", code)
+		case <-time.After(time.Second):
+			return nil
+		}
+	}
+	action = func(ctx context.Context, c Command) error {
+		signals <- os.Interrupt
+		signals <- syscall.SIGTERM
+		return waitForExit(ctx, c)
+	}
+	err = RunContext(context.Background(), []string{"the"}, &tempTree)
+	if err == nil || !strings.HasSuffix(err.Error(), "exit 143") {
+		t.Errorf("Expected a second signal to exit with 143, got %v", err)
+	}
+
+	tempTree.GracePeriod = 10 * time.Millisecond
+	action = func(ctx context.Context, c Command) error {
+		signals <- os.Interrupt
+		return waitForExit(ctx, c)
+	}
+	err = RunContext(context.Background(), []string{"the"}, &tempTree)
+	if err == nil || !strings.HasSuffix(err.Error(), "exit 130") {
+		t.Errorf("Expected the end of the grace period to exit with 130, got %v", err)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	actionRan := false
+	tempTree := NewCommandTree()
+	tempTree.ToHelpString = NoHelp
+	tempTree.Root = Command{
+		Name: "the",
+		Opts: []Option{
+			{LongName: "LongF", ShortName: "f", Description: "Description for Opt LongF", Required: true},
+			{LongName: "LongG", ShortName: "g", Description: "Description for Opt LongG"},
+		},
+		Args: []Argument{
+			{Name: "Arg1", Description: "Description for Arg1", Required: true},
+			{Name: "Arg2", Description: "Description for Arg2", Required: true},
+		},
+		Action: func(c Command) error {
+			actionRan = true
+			return nil
+		},
+	}
+
+	err := Run(strings.Split("the one", " "), &tempTree)
+	if err == nil {
+		t.Fatalf("Run passed with required inputs missing")
+	}
+	for _, missing := range []string{"--LongF", "Description for Opt LongF", "<Arg2>"} {
+		if !strings.Contains(err.Error(), missing) {
+			t.Errorf("Error does not mention %s:\n%s", missing, err.Error())
+		}
+	}
+	if strings.Contains(err.Error(), "<Arg1>") || strings.Contains(err.Error(), "LongG") {
+		t.Errorf("Error mentions inputs that were given or optional:\n%s", err.Error())
+	}
+	if actionRan {
+		t.Errorf("Action ran with required inputs missing")
+	}
+
+	if err = Run(strings.Split("the -f val one two", " "), &tempTree); err != nil {
+		t.Error(err)
+	}
+	if err = Run(strings.Split("the --help", " "), &tempTree); err != nil {
+		t.Errorf("Help failed because of required inputs: %v", err)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	com := Command{
+		Name:  "fetch",
+		Flags: []Flag{{ShortName: "q", LongName: "quiet"}, {ShortName: "v", LongName: "verbose"}, {LongName: "cache", Default: true}},
+		Opts: []Option{
+			{LongName: "file"}, {LongName: "url"},
+			{ShortName: "u", LongName: "user"}, {LongName: "password"},
+			{LongName: "cert"}, {LongName: "key"},
+			{LongName: "retries", Default: "3"},
+		},
+		Groups: []Group{
+			{Kind: GroupMutuallyExclusive, Members: []string{"q", "v", "cache"}},
+			{Kind: GroupExactlyOne, Members: []string{"file", "url"}},
+			{Kind: GroupRequires, Members: []string{"user", "password"}},
+			{Kind: GroupAllOrNone, Members: []string{"cert", "key"}},
+		},
+	}
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"fetch --url x", ""},
+		{"fetch --file x -u me --password pw --cert c --key k -q --retries 1", ""},
+		{"fetch --url x -q -v", "cli: --quiet and --verbose can not be used together"},
+		{"fetch", "cli: Exactly one of --file, --url is required"},
+		{"fetch --file x --url y", "cli: --file and --url can not be used together"},
+		{"fetch --url x -u me", "cli: --user requires --password"},
+		{"fetch --url x --password pw", ""},
+		{"fetch --url x --key k", "cli: --cert and --key must be used together, missing --cert"},
+		{"fetch --url x --no-cache", ""},
+	}
+	com.Flags[2].Negatable = true
+	for _, test := range tests {
+		userCom, err := ParseArgs(strings.Split(test.line, " "), com)
+		if err != nil {
+			t.Errorf("%s: %s", test.line, err)
+			continue
+		}
+		err = CheckGroups(com, userCom)
+		if (test.expected == "" && err != nil) || (test.expected != "" && (err == nil || err.Error() != test.expected)) {
+			t.Errorf("%s: expected error %q, got %v", test.line, test.expected, err)
+		}
+	}
+
+	noDefaults := Command{
+		Name:   "fetch",
+		Flags:  []Flag{{LongName: "cache"}},
+		Opts:   []Option{{LongName: "retries"}},
+		Groups: []Group{{Kind: GroupAtLeastOne, Members: []string{"retries", "cache"}}},
+	}
+	userCom, _ := ParseArgs([]string{"fetch"}, noDefaults)
+	err := CheckGroups(noDefaults, userCom)
+	if err == nil || err.Error() != "cli: At least one of --retries, --cache is required" {
+		t.Errorf("Expected at least one of --retries, --cache to be required, got %v", err)
+	}
+
+	tempTree := NewCommandTree()
+	tempTree.Root = com
+	if err := Run(strings.Split("fetch --file x --url y", " "), &tempTree); err == nil {
+		t.Errorf("Run passed with mutually exclusive inputs")
+	}
+
+	help := ToHelpString(com, nil)
+	if !strings.Contains(help, " Groups:\n  --quiet, --verbose, --cache  at most one may be given\n") ||
+		!strings.Contains(help, "--user, --password           --user requires --password\n") {
+		t.Errorf("Groups missing from help:\n%s", help)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	defaultCom := Command{
+		Name:  "brown",
+		Flags: []Flag{{LongName: "color", Default: true}, {LongName: "quiet"}},
+		Opts: []Option{
+			{LongName: "count", ShortName: "c", Default: "3", Type: IntType, Description: "How many"},
+			{LongName: "name", Default: "fox"},
+		},
+		Args: []Argument{
+			{Name: "Arg1", Default: "one"},
+			{Name: "Arg2", Default: "two"},
+		},
+	}
+
+	userCom, err := ParseArgs(strings.Split("the quick brown --name bear first", " "), defaultCom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userCom.Flags) != 1 || userCom.Flags[0].LongName != "color" || userCom.Flags[0].Source != SourceDefault {
+		t.Errorf("Default flag not filled: %v", FlagArrayToStringArray(userCom.Flags))
+	}
+	if len(userCom.Opts) != 2 {
+		t.Fatalf("Expected 2 options, got %v", OptArrayToStringArray(userCom.Opts))
+	}
+	if userCom.Opts[0].Value != "bear" || userCom.Opts[0].Source != SourceUser {
+		t.Errorf("User option was overwritten by default: %v", userCom.Opts[0].String())
+	}
+	if userCom.Opts[1].TypedValue != 3 || userCom.Opts[1].Source != SourceDefault {
+		t.Errorf("Default option not filled: %v", userCom.Opts[1].String())
+	}
+	if len(userCom.Args) != 2 || userCom.Args[0].Value != "first" || userCom.Args[1].Value != "two" || userCom.Args[1].Source != SourceDefault {
+		t.Errorf("Default argument not filled: %v", ArgArrayToStringArray(userCom.Args))
+	}
+
+	help := ToHelpString(defaultCom, nil)
+	if !strings.Contains(help, "How many (default: 3)") {
+		t.Errorf("Default missing from help:\n%s", help)
+	}
+}
+
+func TestEnvVars(t *testing.T) {
+	os.Setenv("CLI_TEST_COUNT", "7")
+	os.Setenv("CLI_TEST_NAME", "bear")
+	os.Setenv("CLI_TEST_VERBOSE", "true")
+	os.Setenv("MYTOOL_BROWN_COLOR", "red")
+	defer os.Unsetenv("CLI_TEST_COUNT")
+	defer os.Unsetenv("CLI_TEST_NAME")
+	defer os.Unsetenv("CLI_TEST_VERBOSE")
+	defer os.Unsetenv("MYTOOL_BROWN_COLOR")
+
+	envCom := Command{
+		Name:  "brown",
+		Flags: []Flag{{LongName: "verbose", EnvVars: []string{"CLI_TEST_VERBOSE"}}},
+		Opts: []Option{
+			{LongName: "count", Type: IntType, Default: "1", EnvVars: []string{"CLI_TEST_UNSET", "CLI_TEST_COUNT"}},
+			{LongName: "name", Default: "fox", EnvVars: []string{"CLI_TEST_NAME"}},
+		},
+	}
+
+	userCom, err := ParseArgs(strings.Split("the quick brown --name cow", " "), envCom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userCom.Flags) != 1 || userCom.Flags[0].Source != SourceEnv {
+		t.Errorf("Flag not read from env: %v", FlagArrayToStringArray(userCom.Flags))
+	}
+	if userCom.Opts[0].Value != "cow" || userCom.Opts[0].Source != SourceUser {
+		t.Errorf("Command line did not take precedence over env: %s", userCom.Opts[0].String())
+	}
+	if userCom.Opts[1].TypedValue != 7 || userCom.Opts[1].Origin != "CLI_TEST_COUNT" {
+		t.Errorf("Env did not take precedence over default: %s", userCom.Opts[1].String())
+	}
+
+	var colorCom Command
+	tempTree := NewCommandTree()
+	tempTree.EnvPrefix = "MYTOOL_"
+	tempTree.Root = Command{
+		Name: "the",
+		SubCommands: []Command{{
+			Name: "brown",
+			Opts: []Option{{LongName: "color"}},
+			Action: func(c Command) error {
+				colorCom = c
+				return nil
+			},
+		}},
+	}
+	if err = Run(strings.Split("the brown", " "), &tempTree); err != nil {
+		t.Fatal(err)
+	}
+	if len(colorCom.Opts) != 1 || colorCom.Opts[0].Value != "red" {
+		t.Errorf("Derived env var not used: %v", OptArrayToStringArray(colorCom.Opts))
+	}
+
+	help := ToHelpString(envCom, nil)
+	if !strings.Contains(help, "[env: CLI_TEST_NAME]") {
+		t.Errorf("Env var missing from help:\n%s", help)
+	}
+
+	tempTree.Root.Flags = []Flag{{LongName: "quiet", Persistent: true}}
+	tempTree.ToHelpString = func(c Command, pathToCom []string) string {
+		help = tempTree.HelpString(c, pathToCom)
+		return ""
+	}
+	if err = Run(strings.Split("the brown --help", " "), &tempTree); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(help, "[env: MYTOOL_BROWN_COLOR]") || !strings.Contains(help, "[env: MYTOOL_BROWN_QUIET]") {
+		t.Errorf("Derived env vars missing from help:\n%s", help)
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	toml, err := parseTOMLConfig([]byte("top = 1\n[quick.brown]\ncolor = \"x # y\" # comment\ntags = [\"a\", \"b\"]\nquiet = true\n"))
+	if err != nil || toml["top"] != "1" || toml["quick.brown.color"] != "x # y" || toml["quick.brown.tags"] != "a,b" || toml["quick.brown.quiet"] != "true" {
+		t.Errorf("TOML parsed incorrectly: %v %v", toml, err)
+	}
+
+	yaml, err := parseYAMLConfig([]byte("quick:\n  brown:\n    color: 'x' # comment\n    tags:\n      - a\n      - b\n  red: [c, d]\ntop: 1\n"))
+	if err != nil || yaml["quick.brown.color"] != "x" || yaml["quick.brown.tags"] != "a,b" || yaml["quick.red"] != "c,d" || yaml["top"] != "1" {
+		t.Errorf("YAML parsed incorrectly: %v %v", yaml, err)
+	}
+
+	ini, err := parseINIConfig([]byte("; comment\n[quick.brown]\ncolor = \"x\"\n"))
+	if err != nil || ini["quick.brown.color"] != "x" {
+		t.Errorf("INI parsed incorrectly: %v %v", ini, err)
+	}
+
+	json, err := parseJSONConfig([]byte(`{"quick": {"brown": {"color": "x", "count": 3, "tags": ["a", "b"]}}}`))
+	if err != nil || json["quick.brown.color"] != "x" || json["quick.brown.count"] != "3" || json["quick.brown.tags"] != "a,b" {
+		t.Errorf("JSON parsed incorrectly: %v %v", json, err)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	system := writeFile("system.toml", "size = \"small\"\n[brown]\ncolor = \"blue\"\ncount = 2\n")
+	user := writeFile("user.yaml", "brown:\n  color: green\n")
+	explicit := writeFile("explicit.json", `{"brown": {"name": "cow"}}`)
+
+	os.Setenv("CLI_TEST_CONFIG_COUNT", "5")
+	defer os.Unsetenv("CLI_TEST_CONFIG_COUNT")
+
+	var userCom Command
+	tempTree := NewCommandTree()
+	tempTree.AutoConfig = true
+	tempTree.ConfigFiles = []string{system, filepath.Join(dir, "missing.ini"), user}
+	tempTree.Root = Command{
+		Name: "the",
+		SubCommands: []Command{{
+			Name: "brown",
+			Opts: []Option{
+				{LongName: "color"},
+				{LongName: "size"},
+				{LongName: "count", Type: IntType, EnvVars: []string{"CLI_TEST_CONFIG_COUNT"}},
+				{LongName: "name", Default: "fox"},
+			},
+			Action: func(c Command) error {
+				userCom = c
+				return nil
+			},
+		}},
+	}
+
+	if err := Run(strings.Split("the brown --config "+explicit, " "), &tempTree); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"color": "green", "size": "small", "count": "5", "name": "cow"}
+	for name, val := range expected {
+		opt, found := userCom.lookupOption(Option{LongName: name})
+		if !found || opt.Value != val {
+			t.Errorf("Expected --%s to be %s, got %q", name, val, opt.Value)
+		}
+	}
+	if opt, _ := userCom.lookupOption(Option{LongName: "color"}); opt.Source != SourceConfig || opt.Origin != user {
+		t.Errorf("Expected --color to come from %s, got %s", user, describeSource(opt.Source, opt.Origin))
+	}
+
+	show := ShowConfig(tempTree.Root.SubCommands[0], userCom)
+	if !strings.Contains(show, "--color  green  config "+user) || !strings.Contains(show, "env CLI_TEST_CONFIG_COUNT") {
+		t.Errorf("ShowConfig did not report the sources:\n%s", show)
+	}
+
+	if err := Run(strings.Split("the brown --config "+filepath.Join(dir, "missing.json"), " "), &tempTree); err == nil {
+		t.Errorf("Run passed with a missing explicit config file")
+	}
+
+	// Running the tree again does not add the auto inputs to it twice
+	var helpCom Command
+	tempTree.ToHelpString = func(c Command, pathToCom []string) string {
+		helpCom = c
+		return ""
+	}
+	for i := 0; i < 3; i++ {
+		if err := Run(strings.Split("the brown --help", " "), &tempTree); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tempTree.Shared.Args != nil || tempTree.Shared.Flags != nil || tempTree.Shared.Opts != nil {
+		t.Errorf("Run added the auto inputs to the tree: %v", tempTree.Shared)
+	}
+	numConfig := 0
+	for _, o := range helpCom.Opts {
+		if isAutoOption(o) {
+			numConfig++
+		}
+	}
+	if numConfig != 1 {
+		t.Errorf("Expected --config once after running the tree again, got %d", numConfig)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	var seenCom Command
+	tempTree := NewCommandTree()
+	tempTree.Root = Command{
+		Name: "the",
+		SubCommands: []Command{
+			{
+				Name:        "brown",
+				Description: "the brown",
+				Flags:       []Flag{{LongName: "verbose", ShortName: "v"}},
+				Opts: []Option{
+					{LongName: "format", ShortName: "f", Choices: []string{"json", "yaml", "table"}},
+					{LongName: "env", Complete: func(com Command, prefix string) ([]Completion, CompletionDirective) {
+						return []Completion{{"prod", "production"}, {"staging", "staging"}}, CompleteNoSpace
+					}},
+					{LongName: "file", Complete: func(com Command, prefix string) ([]Completion, CompletionDirective) {
+						return []Completion{{Value: "yaml"}, {Value: "yml"}}, CompleteFileExt
+					}},
+				},
+				Args: []Argument{
+					{Name: "cluster", Complete: func(com Command, prefix string) ([]Completion, CompletionDirective) {
+						seenCom = com
+						return []Completion{{"east", ""}, {"west", ""}}, CompleteNoFiles
+					}},
+					{Name: "dir", Complete: func(com Command, prefix string) ([]Completion, CompletionDirective) {
+						return nil, CompleteDirs
+					}},
+				},
+			},
+			{Name: "bear"},
+			{Name: "red"},
+		},
+	}
+	tempTree.Shared.Flags = []Flag{{LongName: "debug"}}
+
+	completeHelper(t, tempTree, "the b", "brown bear", CompleteNoFiles)
+	completeHelper(t, tempTree, "the ", "brown bear red", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown --", "--debug --env --file --format --verbose", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown --f", "--file --format", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown --format ", "json yaml table", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown -f y", "yaml", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown --format=t", "--format=table", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown --env p", "prod staging", CompleteNoSpace)
+	completeHelper(t, tempTree, "the brown --file=", "yaml yml", CompleteFileExt)
+
+	// Bash splits words at "=" and ":" and only replaces the part after the split
+	completeHelper(t, tempTree, "the brown --format = t", "table", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown --format =", "json yaml table", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown --file =", "yaml yml", CompleteFileExt)
+	if words, kept := joinBrokenWords(strings.Split("the --url http : //ex", " ")); strings.Join(words, " ") != "the --url http://ex" || kept != "http:" {
+		t.Errorf("Expected the URL to be joined with http: kept, got %q %q", words, kept)
+	}
+	completeHelper(t, tempTree, "the brown -v --format json ", "east west", CompleteNoFiles)
+	completeHelper(t, tempTree, "the brown east ", "", CompleteDirs)
+	completeHelper(t, tempTree, "the bear ", "", CompleteDefault)
+
+	if !seenCom.hasFlag("v") || !seenCom.hasOption("format") {
+		t.Errorf("Completion function was not given the parsed Command: %s", seenCom.String())
+	}
+
+	for _, gen := range []func(io.Writer, *CommandTree) error{GenBashCompletion, GenZshCompletion, GenFishCompletion, GenPowerShellCompletion} {
+		var buf bytes.Buffer
+		if err := gen(&buf, &tempTree); err != nil {
+			t.Error(err)
+		}
+		if !strings.Contains(buf.String(), "__complete") {
+			t.Errorf("Completion script does not call __complete:\n%s", buf.String())
+		}
+	}
+}
+
+func completeHelper(t *testing.T, tree CommandTree, line string, expected string, expectedDirective CompletionDirective) {
+	candidates, directive := tree.Complete(strings.Split(line, " "))
+	var values []string
+	for _, c := range candidates {
+		values = append(values, c.Value)
+	}
+	if strings.Join(values, " ") != expected || directive != expectedDirective {
+		t.Errorf("Completing %q: expected %q %d, got %q %d", line, expected, expectedDirective, strings.Join(values, " "), directive)
+	}
+}
+
+func TestManPages(t *testing.T) {
+	dir := t.TempDir()
+	tempTree := comTree
+	tempTree.Copyright = "Copyright 2016"
+	tempTree.Email = "jeff@example.com"
+	if err := GenManTree(&tempTree, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"the.1", "the-quick.1", "the-quick-brown.1", "the-quick-brown-fox.1", "the-quick-brown-bear.1", "the-quick-red.1"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Man page %s was not written", name)
+		}
+	}
+
+	// Every page in SEE ALSO must have been written
+	pages, _ := filepath.Glob(filepath.Join(dir, "*.1"))
+	seeAlso := regexp.MustCompile(`\\fB(\S+?)\\fR\(1\)`)
+	for _, path := range pages {
+		page, _ := ioutil.ReadFile(path)
+		for _, match := range seeAlso.FindAllStringSubmatch(string(page), -1) {
+			name := strings.Replace(match[1], `\-`, "-", -1) + ".1"
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Errorf("%s links to %s, which was not written", filepath.Base(path), name)
+			}
+		}
+	}
+
+	page, err := ioutil.ReadFile(filepath.Join(dir, "the-quick-brown.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`.TH "THE\-QUICK\-BROWN" "1" "" "the 0.1"`,
+		`the\-quick\-brown \- the quick brown`,
+		`\fBthe quick brown\fR [\fB\-\-LongA\fR]`,
+		".SH DESCRIPTION",
+		`\fB\-f, \-\-LongF\fR \fIvalue\fR`,
+		`\fBthe\-quick\fR(1)`,
+		`\fBthe\-quick\-brown\-fox\fR(1)`,
+		"Jeff Williams <jeff@example.com>",
+		".SH COPYRIGHT",
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("Man page is missing %q:\n%s", expected, page)
+		}
+	}
+
+	var buf bytes.Buffer
+	bare := CommandTree{Root: Command{Name: "the", Description: "the bare"}}
+	if err := GenManPage(&buf, &bare, Node{Command: bare.Root}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), ".SH OPTIONS") || strings.Contains(buf.String(), ".SH ARGUMENTS") {
+		t.Errorf("Man page for a command without inputs has empty sections:\n%s", buf.String())
+	}
+}
+
+func TestDocs(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenMarkdownTree(&comTree, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(dir, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), "    * [the quick brown fox](the-quick-brown-fox.md) - the quick brown fox") {
+		t.Errorf("Index is missing a link to fox:\n%s", index)
+	}
+	if !strings.Contains(string(index), "[the quick brown bear](the-quick-brown-bear.md)") {
+		t.Errorf("Index is missing a link to a command with HideHelp:\n%s", index)
+	}
+
+	// Every page linked to must have been written
+	pages, _ := filepath.Glob(filepath.Join(dir, "*.md"))
+	mdLink := regexp.MustCompile(`\]\(([^)]+\.md)\)`)
+	for _, path := range pages {
+		page, _ := ioutil.ReadFile(path)
+		for _, match := range mdLink.FindAllStringSubmatch(string(page), -1) {
+			if _, err := os.Stat(filepath.Join(dir, match[1])); err != nil {
+				t.Errorf("%s links to %s, which was not written", filepath.Base(path), match[1])
+			}
+		}
+	}
+
+	page, err := ioutil.ReadFile(filepath.Join(dir, "the-quick-brown.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<a id="the-quick-brown"></a>`,
+		"[the](the.md) › [quick](the-quick.md) › brown",
+		"| [fox](the-quick-brown-fox.md) | the quick brown fox |",
+		"| `<Arg1>` | Description for Arg1 |",
+		"| `-f` | `--LongF` | Description for Opt LongF |",
+	} {
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("Markdown page is missing %q:\n%s", expected, page)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := GenHTML(&buf, &comTree); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<section id="the-quick-brown">`,
+		`<a href="#the-quick-brown-fox">fox</a>`,
+		`<a href="#the">the</a> › <a href="#the-quick">quick</a> › brown`,
+		`<code>&lt;Arg1&gt;</code>`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("HTML is missing %q", expected)
+		}
+	}
+
+	buf.Reset()
+	bare := CommandTree{Root: Command{Name: "the", Description: "the bare"}}
+	if err := GenMarkdown(&buf, &bare, Node{Command: bare.Root}); err != nil {
+		t.Fatal(err)
+	}
+	for _, section := range []string{"## Arguments", "## Flags", "## Options"} {
+		if strings.Contains(buf.String(), section) {
+			t.Errorf("Markdown for a command without inputs has an empty %q table:\n%s", section, buf.String())
+		}
+	}
+}
+
 func TestSharedParameters(t *testing.T) {
 
 	shared := SharedParameters{
@@ -225,6 +1351,47 @@ func TestOptionDuplication(t *testing.T) {
 	parseHelper(t, "the quick brown -f val -f val", *QuickBrown, expected1)
 }
 
+func TestRepeatable(t *testing.T) {
+	com := Command{
+		Name:  "build",
+		Flags: []Flag{{ShortName: "v", LongName: "verbose"}},
+		Opts: []Option{
+			{ShortName: "t", LongName: "tag", Repeatable: true, Separator: ","},
+			{ShortName: "o", LongName: "output", Duplicates: DuplicateLastWins},
+			{LongName: "name", Duplicates: DuplicateError},
+			{LongName: "mode"},
+		},
+	}
+
+	userCom, err := ParseArgs(strings.Split("build -vvv -t a --tag b,c -o one --output two --mode x --mode y", " "), com)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userCom.Count("verbose") != 3 || len(userCom.Flags) != 1 {
+		t.Errorf("Expected -vvv to be counted 3 times, got %d", userCom.Count("verbose"))
+	}
+	if strings.Join(userCom.Strings("tag"), " ") != "a b c" {
+		t.Errorf("Expected tags a b c, got %v", userCom.Strings("tag"))
+	}
+	if userCom.Value("output") != "two" || len(userCom.Strings("output")) != 1 {
+		t.Errorf("Expected the last --output to win, got %v", userCom.Strings("output"))
+	}
+	if userCom.Value("mode") != "x" {
+		t.Errorf("Expected the first --mode to win, got %s", userCom.Value("mode"))
+	}
+
+	_, err = ParseArgs(strings.Split("build --name a --name b", " "), com)
+	expected := "cli: Option --name given more than once"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+
+	userCom, err = ParseArgs(strings.Split("build -v -v", " "), com)
+	if err != nil || userCom.Count("v") != 2 {
+		t.Errorf("Expected -v -v to be counted twice, got %d %v", userCom.Count("v"), err)
+	}
+}
+
 func TestOptionShortForm(t *testing.T) {
 
 	var expected1 Command = Command{
@@ -251,6 +1418,179 @@ func TestOptionShortForm(t *testing.T) {
 
 }
 
+func TestTokenizer(t *testing.T) {
+	tar := Command{
+		Name:  "tar",
+		Flags: []Flag{{ShortName: "x"}, {ShortName: "v"}, {ShortName: "z", LongName: "gzip"}},
+		Opts: []Option{
+			{ShortName: "f", LongName: "file"},
+			{ShortName: "o", LongName: "output"},
+			{LongName: "filter"},
+		},
+		Args: []Argument{{Name: "files", Variadic: true}},
+	}
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"tar -xvf file", "-x -v f=file"},
+		{"tar -xvffile", "-x -v f=file"},
+		{"tar -ofile", "o=file"},
+		{"tar -o=file", "o=file"},
+		{"tar -o -x", "o=-x"},
+		{"tar --file=", "file="},
+		{"tar --file=a=b", "file=a=b"},
+		{"tar --filter a=b", "filter=a=b"},
+		{"tar --gzip key=value", "--gzip <key=value>"},
+		{"tar -- -x --file", "<-x> <--file>"},
+		{"tar -x -- --", "-x <-->"},
+		{"tar - -v", "-v <->"},
+	}
+	for _, test := range tests {
+		userCom, err := ParseArgs(strings.Split(test.line, " "), tar)
+		if err != nil {
+			t.Errorf("%s: %s", test.line, err)
+			continue
+		}
+		var parsed []string
+		for _, f := range userCom.Flags {
+			parsed = append(parsed, f.displayName())
+		}
+		for _, o := range userCom.Opts {
+			parsed = append(parsed, o.name()+"="+o.Value)
+		}
+		for _, a := range userCom.Args {
+			parsed = append(parsed, "<"+a.Value+">")
+		}
+		if strings.Join(parsed, " ") != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.line, test.expected, strings.Join(parsed, " "))
+		}
+	}
+
+	errTests := []struct {
+		line     string
+		expected string
+	}{
+		{"tar --file", "cli: No value provided for option --file"},
+		{"tar -xf", "cli: No value provided for option -f"},
+		{"tar --gzip=yes", "cli: Invalid value \"yes\" for flag --gzip: expected true or false"},
+		{"tar -xz=1", "cli: Flag -z does not take a value"},
+		{"tar -xq", "cli: Short form input -q not found in -xq"},
+		{"tar -q", "cli: Short form input -q not found"},
+	}
+	for _, test := range errTests {
+		_, err := ParseArgs(strings.Split(test.line, " "), tar)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected error %q, got %v", test.line, test.expected, err)
+		}
+	}
+}
+
+func TestNegatableFlags(t *testing.T) {
+	com := Command{
+		Name: "build",
+		Flags: []Flag{
+			{ShortName: "c", LongName: "color", Negatable: true, EnvVars: []string{"CLI_TEST_COLOR"}},
+			{LongName: "cache", Default: true},
+		},
+	}
+	os.Setenv("CLI_TEST_COLOR", "true")
+	defer os.Unsetenv("CLI_TEST_COLOR")
+
+	tests := []struct {
+		line    string
+		isSet   bool
+		on      bool
+		changed bool
+	}{
+		{"build", true, true, false},
+		{"build --no-color", true, false, true},
+		{"build --color=false", true, false, true},
+		{"build --no-color --color", true, true, true},
+		{"build -c --color=0", true, false, true},
+	}
+	for _, test := range tests {
+		userCom, err := ParseArgs(strings.Split(test.line, " "), com)
+		if err != nil {
+			t.Errorf("%s: %s", test.line, err)
+			continue
+		}
+		if userCom.IsSet("color") != test.isSet || userCom.Bool("c") != test.on || userCom.Changed("color") != test.changed {
+			t.Errorf("%s: expected set %t, on %t, changed %t, got %t, %t, %t", test.line, test.isSet, test.on, test.changed,
+				userCom.IsSet("color"), userCom.Bool("c"), userCom.Changed("color"))
+		}
+	}
+
+	os.Setenv("CLI_TEST_COLOR", "false")
+	userCom, err := ParseArgs([]string{"build", "--cache=false"}, com)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !userCom.IsSet("color") || userCom.Bool("color") || userCom.Bool("cache") {
+		t.Errorf("Expected color and cache to be turned off, got %v", userCom.Flags)
+	}
+
+	os.Unsetenv("CLI_TEST_COLOR")
+	userCom, _ = ParseArgs([]string{"build"}, com)
+	if userCom.IsSet("color") || !userCom.Bool("cache") {
+		t.Errorf("Expected color to be unset and cache on by default, got %v", userCom.Flags)
+	}
+
+	assertParseFails(t, "build --no-cache", com)
+	assertParseFails(t, "build --no-color=true", com)
+
+	help := ToHelpString(com, nil)
+	if !strings.Contains(help, "-c  --[no-]color,") {
+		t.Errorf("Negatable flag missing from help:\n%s", help)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	com := Command{
+		Name: "report",
+		Opts: []Option{
+			{ShortName: "f", LongName: "format", Choices: []string{"json", "yaml", "table"}},
+			{LongName: "name", Pattern: "[a-z]+"},
+			{LongName: "count", Type: IntType, Range: &Range{Min: 1, Max: 10}},
+			{LongName: "even", Type: IntType, Validate: func(val interface{}) error {
+				if val.(int)%2 != 0 {
+					return errors.New("expected an even number")
+				}
+				return nil
+			}},
+		},
+		Args: []Argument{{Name: "level", Choices: []string{"low", "high"}}},
+	}
+
+	assertParsePasses(t, "report --format yaml --name abc --count 10 --even 4 high", com)
+
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"report --format=xlm", `cli: Invalid value "xlm" for option --format: expected one of json, yaml, table`},
+		{"report --name abc1", `cli: Invalid value "abc1" for option --name: expected a value matching [a-z]+`},
+		{"report --count 11", `cli: Invalid value "11" for option --count: expected a number from 1 to 10`},
+		{"report --even 3", `cli: Invalid value "3" for option --even: expected an even number`},
+		{"report medium", `cli: Invalid value "medium" for argument <level>: expected one of low, high`},
+	}
+	for _, test := range tests {
+		_, err := ParseArgs(strings.Split(test.line, " "), com)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected error %q, got %v", test.line, test.expected, err)
+		}
+	}
+
+	help := ToHelpString(com, nil)
+	if !strings.Contains(help, "--format {json,yaml,table},") || !strings.Contains(help, "<level> {low,high}") {
+		t.Errorf("Choices missing from help:\n%s", help)
+	}
+
+	tree := CommandTree{Root: com}
+	completeHelper(t, tree, "report h", "high", CompleteNoFiles)
+}
+
 func TestArgSets(t *testing.T) {
 	var testCom1 Command = Command{
 		Name:        "brown",
@@ -271,6 +1611,180 @@ func TestArgSets(t *testing.T) {
 	}
 	assertParsePasses(t, "the quick brown one two", testCom2)
 	assertParsePasses(t, "the quick brown one two three", testCom2)
+
+	copyCom := Command{
+		Name: "copy",
+		Args: []Argument{
+			{Name: "src", Required: true},
+			{Name: "dst", Required: true},
+		},
+		ArgSets: []ArgumentSet{{Set: []Argument{
+			{Name: "src", Required: true},
+			{Name: "src2", Required: true},
+			{Name: "dir", Required: true},
+		}}},
+	}
+	userCom, err := ParseArgs([]string{"copy", "a", "b"}, copyCom)
+	if err != nil {
+		t.Error(err)
+	} else if userCom.MatchedArgSet != 0 || userCom.Args[1].Name != "dst" {
+		t.Errorf("Expected a b to match Args, matched %d with %v", userCom.MatchedArgSet, userCom.Args)
+	}
+
+	userCom, err = ParseArgs([]string{"copy", "a", "b", "c"}, copyCom)
+	if err != nil {
+		t.Error(err)
+	} else if userCom.MatchedArgSet != 1 || userCom.Args[2].Name != "dir" {
+		t.Errorf("Expected a b c to match ArgSets[0], matched %d with %v", userCom.MatchedArgSet, userCom.Args)
+	}
+
+	// A Command that was never bound is checked against Args
+	handMade := Command{Name: "copy", Args: []Argument{{Name: "src", Value: "a"}, {Name: "dst", Value: "b"}}}
+	if err = CheckRequired(copyCom, handMade); err != nil {
+		t.Errorf("Expected an unbound Command to be checked against Args, got %v", err)
+	}
+
+	_, err = ParseArgs([]string{"copy", "a"}, copyCom)
+	expected := "cli: Arguments for copy match none of its usages:\n  copy <src> <dst>\n  copy <src> <src2> <dir>"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+
+	help := ToHelpString(copyCom, []string{"the"})
+	if !strings.Contains(help, "Usage: \n       the copy <src> <dst>\n       the copy <src> <src2> <dir>\n") {
+		t.Errorf("ArgSet usages missing from help:\n%s", help)
+	}
+}
+
+func TestPositionalBinding(t *testing.T) {
+	copyCom := Command{
+		Name: "copy",
+		Args: []Argument{
+			{Name: "src", Required: true, Variadic: true},
+			{Name: "dir", Required: true},
+			{Name: "mode", Default: "0644"},
+		},
+	}
+
+	userCom, err := ParseArgs([]string{"copy", "a", "b", "c", "d"}, copyCom)
+	if err != nil {
+		t.Error(err)
+	}
+	names := []string{}
+	for _, a := range userCom.Args {
+		names = append(names, a.Name+"="+a.Value)
+	}
+	expected := "src=a src=b dir=c mode=d"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(names, " "))
+	}
+
+	userCom, err = ParseArgs([]string{"copy", "a", "b"}, copyCom)
+	if err != nil {
+		t.Error(err)
+	}
+	names = []string{}
+	for _, a := range userCom.Args {
+		names = append(names, a.Name+"="+a.Value)
+	}
+	expected = "src=a dir=b mode=0644"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(names, " "))
+	}
+
+	userCom, err = ParseArgs([]string{"copy", "a"}, copyCom)
+	if err != nil {
+		t.Error(err)
+	}
+	err = CheckRequired(copyCom, userCom)
+	if err == nil || !strings.Contains(err.Error(), "<dir>") {
+		t.Errorf("Expected <dir> to be missing, got %v", err)
+	}
+
+	// Required Arguments are filled before an optional one in front of them
+	greetCom := Command{
+		Name: "greet",
+		Args: []Argument{{Name: "greeting", Default: "hello"}, {Name: "name", Required: true}},
+	}
+	for line, expected := range map[string][2]string{
+		"greet fox":    {"hello", "fox"},
+		"greet hi fox": {"hi", "fox"},
+	} {
+		userCom, err = ParseArgs(strings.Split(line, " "), greetCom)
+		if err == nil {
+			err = CheckRequired(greetCom, userCom)
+		}
+		if err != nil {
+			t.Errorf("%s: %s", line, err)
+		} else if userCom.Arg("greeting") != expected[0] || userCom.Arg("name") != expected[1] {
+			t.Errorf("%s: expected greeting=%s name=%s, got %s", line, expected[0], expected[1], userCom.String())
+		}
+	}
+
+	twoArgCom := Command{Name: "move", Args: TwoArg}
+	_, err = ParseArgs([]string{"move", "a", "b", "c"}, twoArgCom)
+	expected = "cli: too many arguments for move: expected at most 2 but got 3"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+
+	limitCom := Command{
+		Name: "tag",
+		Args: []Argument{{Name: "tag", Variadic: true, Min: 2, Max: 3}},
+	}
+	assertParseFails(t, "tag a b c d", limitCom)
+	userCom, _ = ParseArgs([]string{"tag", "a"}, limitCom)
+	if CheckRequired(limitCom, userCom) == nil {
+		t.Errorf("Expected a single tag to be too few")
+	}
+	userCom, _ = ParseArgs([]string{"tag", "a", "b", "c"}, limitCom)
+	if err := CheckRequired(limitCom, userCom); err != nil {
+		t.Error(err)
+	}
+
+	help := ToHelpString(copyCom, nil)
+	if !strings.Contains(help, "<src>...") {
+		t.Errorf("Variadic argument missing from help:\n%s", help)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	com := Command{
+		Name:  "deploy",
+		Flags: []Flag{{ShortName: "v", LongName: "verbose"}, {LongName: "dry-run", Default: true}},
+		Opts: []Option{
+			{ShortName: "o", LongName: "output"},
+			{ShortName: "n", LongName: "count", Type: IntType, Default: "3"},
+			{LongName: "tag", Type: StringSliceType},
+		},
+		Args: []Argument{{Name: "src", Variadic: true}, {Name: "dst"}},
+	}
+	userCom, err := ParseArgs(strings.Split("deploy -v -o out.txt --tag a,b one two three", " "), com)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !userCom.IsSet("verbose") || !userCom.IsSet("v") || userCom.Count("verbose") != 1 {
+		t.Errorf("Expected -v to be set under both names")
+	}
+	if userCom.Value("output") != "out.txt" || userCom.Value("o") != "out.txt" || userCom.Value("missing") != "" {
+		t.Errorf("Unexpected option values %q, %q", userCom.Value("output"), userCom.Value("missing"))
+	}
+	if userCom.Int("count") != 3 || userCom.Int("n") != 3 {
+		t.Errorf("Expected count to default to 3, got %d", userCom.Int("count"))
+	}
+	if strings.Join(userCom.Strings("tag"), " ") != "a b" {
+		t.Errorf("Expected tags a b, got %v", userCom.Strings("tag"))
+	}
+	if userCom.Arg("src") != "one" || userCom.Arg("dst") != "three" || strings.Join(userCom.ArgValues("src"), " ") != "one two" {
+		t.Errorf("Unexpected arguments %v", userCom.Args)
+	}
+	if !userCom.IsSet("dry-run") || userCom.Changed("dry-run") || !userCom.Changed("v") {
+		t.Errorf("Expected --dry-run to be set by default and -v by the user")
+	}
+	if userCom.Changed("count") || !userCom.Changed("output") || !userCom.Changed("src") {
+		t.Errorf("Expected only user given inputs to be changed")
+	}
 }
 
 func assertParsePasses(t *testing.T, appArgs string, fullCom Command) {
@@ -278,7 +1792,7 @@ func assertParsePasses(t *testing.T, appArgs string, fullCom Command) {
 	_, err := ParseArgs(argArray, fullCom)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 }
 
@@ -302,7 +1816,7 @@ func parseHelper(t *testing.T, appArgs string, fullCom Command, expectedCom Comm
 	userCom, err := ParseArgs(argArray, fullCom)
 
 	if err != nil {
-		t.Errorf(err.Error())
+		t.Error(err)
 	}
 
 	if userCom.String() != expectedCom.String() {
@@ -331,7 +1845,7 @@ func findHelper(t *testing.T, appArgs string, targetCom *Command) {
 		errString := fmt.Sprintf("FindCommand:Path To Command is not correct\n")
 		errString += fmt.Sprintf("argArray: %v\n", argArray)
 		errString += fmt.Sprintf("pathToCom: %v\n", pathToCom)
-		t.Errorf(errString)
+		t.Error(errString)
 	}
 }
 
@@ -1,12 +1,15 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 )
 
 type Command struct {
 	Name        string
+	Aliases     []string
 	Description string
 	Usage       string
 	Flags       []Flag
@@ -15,7 +18,52 @@ type Command struct {
 	Opts        []Option
 	SubCommands []Command
 	HideHelp    bool
-	Action      func(com Command) error
+	Action      ActionFunc
+
+	// PreAction and PostAction run before and after the Action of the command and of every
+	// command below it. PreActions run from the root down and PostActions from the command
+	// back up. Middleware wraps the Action of the command and of every command below it,
+	// with the Middleware of the root outermost.
+	PreAction  ActionFunc
+	PostAction ActionFunc
+	Middleware []Middleware
+
+	// ContextAction is used in place of Action when it is set. It is called with the
+	// context passed to RunContext.
+	ContextAction ContextActionFunc
+
+	// Groups constrain which inputs may be given together. Run checks them after the
+	// required inputs. See CheckGroups.
+	Groups []Group
+
+	// HelpTemplate is a text/template used in place of the tree's template when showing
+	// help for this command. See HelpData for the data it is executed with.
+	HelpTemplate string
+
+	// MatchedArgSet is set on a parsed Command to the signature the positional arguments
+	// matched: 0 for Args, or 1 plus the index of the ArgumentSet in ArgSets. A Command
+	// whose arguments were never matched reads as having matched Args.
+	MatchedArgSet int
+
+	// Err holds the error returned by the PreAction or Action on the Command passed to the
+	// PostAction. It is nil when both succeeded.
+	Err error
+
+	// def is the definition a parsed Command was parsed against. The accessors use it to
+	// find inputs by either of their names.
+	def *Command
+
+	// ctx is the context a parsed Command was run with, returned by Context.
+	ctx context.Context
+}
+
+// Context returns the context the command is being run with. It is cancelled when
+// RunContext receives a signal. It is never nil.
+func (c Command) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
 }
 
 func SubCommandToString(sub *Command) string {
@@ -59,6 +107,23 @@ func (c Command) hasFlag(flagStr string) (found bool) {
 	return false
 }
 
+// hasFlagDef reports whether a parsed Command contains the flag defined by def under
+// either of its names.
+func (c Command) hasFlagDef(def Flag) (found bool) {
+	_, found = c.lookupFlag(def)
+	return found
+}
+
+// lookupFlag returns the entry in a parsed Command for the flag defined by def.
+func (c Command) lookupFlag(def Flag) (flag Flag, found bool) {
+	for _, f := range c.Flags {
+		if sameFlag(f, def) {
+			return f, true
+		}
+	}
+	return flag, false
+}
+
 func (c Command) hasOption(optStr string) (found bool) {
 	for _, opt := range c.Opts {
 		if optStr == opt.ShortName || optStr == opt.LongName {
@@ -68,6 +133,86 @@ func (c Command) hasOption(optStr string) (found bool) {
 	return false
 }
 
+// hasOptionDef reports whether a parsed Command contains the option defined by def under
+// either of its names.
+func (c Command) hasOptionDef(def Option) (found bool) {
+	_, found = c.lookupOption(def)
+	return found
+}
+
+// lookupOption returns the entry in a parsed Command for the option defined by def.
+func (c Command) lookupOption(def Option) (opt Option, found bool) {
+	for _, o := range c.Opts {
+		if sameOption(o, def) {
+			return o, true
+		}
+	}
+	return opt, false
+}
+
+func (c Command) findFlag(flagStr string) (flag Flag, found bool) {
+	for _, flag := range c.Flags {
+		if flagStr == flag.ShortName || flagStr == flag.LongName {
+			return flag, true
+		}
+	}
+	return flag, false
+}
+
+// findNegatedFlag returns the Negatable flag that name, such as "no-color", turns off.
+func (c Command) findNegatedFlag(name string) (flag Flag, found bool) {
+	if !strings.HasPrefix(name, "no-") {
+		return flag, false
+	}
+	for _, flag := range c.Flags {
+		if flag.Negatable && flag.LongName != "" && flag.LongName == name[len("no-"):] {
+			return flag, true
+		}
+	}
+	return flag, false
+}
+
+func (c Command) findOption(optStr string) (opt Option, found bool) {
+	for _, opt := range c.Opts {
+		if optStr == opt.ShortName || optStr == opt.LongName {
+			return opt, true
+		}
+	}
+	return opt, false
+}
+
+// positionalArgs returns the Arguments that are filled by position. Arguments with a
+// Value, such as the "?" used by auto help, are keywords matched by value instead.
+func (c Command) positionalArgs() (args []Argument) {
+	for _, arg := range c.Args {
+		if arg.Value == "" {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// positionalValues returns the Arguments in a parsed Command that were given by position,
+// skipping any that match a keyword Argument of the definition def.
+func (c Command) positionalValues(def Command) (args []Argument) {
+	for _, arg := range c.Args {
+		if !def.hasArg(arg.Value) {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// countArg returns the number of values in a parsed Command bound to the Argument named name.
+func (c Command) countArg(name string) (n int) {
+	for _, arg := range c.Args {
+		if arg.Name == name {
+			n++
+		}
+	}
+	return n
+}
+
 func (c Command) hasArg(argStr string) (found bool) {
 	for _, arg := range c.Args {
 		if argStr == arg.Value {
@@ -77,9 +222,36 @@ func (c Command) hasArg(argStr string) (found bool) {
 	return false
 }
 
+// isNamed reports whether name is the name or one of the aliases of c.
+func (c Command) isNamed(name string) bool {
+	return name == c.Name || containsString(c.Aliases, name)
+}
+
+// expandLongName returns the long name of the Flag or Option that name is a prefix of. It
+// fails when name is a prefix of more than one.
+func (c Command) expandLongName(name string) (longName string, err error) {
+	if _, found := c.findNegatedFlag(name); found || c.hasFlag(name) || c.hasOption(name) {
+		return name, nil
+	}
+	var candidates []string
+	for _, long := range c.longNames() {
+		if long != "" && strings.HasPrefix(long, name) && !containsString(candidates, long) {
+			candidates = append(candidates, long)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return name, nil
+	case 1:
+		return candidates[0], nil
+	}
+	errStr := fmt.Sprintf("cli: ambiguous input --%s, could be --%s", name, strings.Join(candidates, ", --"))
+	return name, errors.New(errStr)
+}
+
 func (c Command) hasSubCommand(comStr string) (found bool) {
 	for _, sub := range c.SubCommands {
-		if comStr == sub.Name {
+		if sub.isNamed(comStr) {
 			return true
 		}
 	}
@@ -0,0 +1,430 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// completeCommand is the hidden command the generated completion scripts call. Run answers
+// "prog __complete word..." by printing one candidate per line for the last word.
+const completeCommand = "__complete"
+
+// Completion is a single candidate offered to the shell during completion.
+type Completion struct {
+	Value       string
+	Description string
+}
+
+// CompletionDirective tells the shell how to treat the candidates returned by a completion.
+// Directives can be combined with |.
+type CompletionDirective int
+
+const (
+	// CompleteDefault lets the shell fall back to its own completion when there are no
+	// candidates.
+	CompleteDefault CompletionDirective = 0
+
+	// CompleteNoSpace keeps the shell from adding a space after the completed word.
+	CompleteNoSpace CompletionDirective = 1 << iota
+
+	// CompleteNoFiles keeps the shell from completing file names when there are no
+	// candidates.
+	CompleteNoFiles
+
+	// CompleteFiles asks the shell to complete file names instead of the candidates.
+	CompleteFiles
+
+	// CompleteFileExt asks the shell to complete file names with one of the extensions
+	// given as the candidates, e.g. "yaml" and "yml".
+	CompleteFileExt
+
+	// CompleteDirs asks the shell to complete directory names instead of the candidates.
+	CompleteDirs
+)
+
+// A CompletionFunc returns the candidates for the value of an Argument or Option. com is
+// the Command parsed from the words typed before the one being completed, and prefix is
+// the part of the value typed so far.
+type CompletionFunc func(com Command, prefix string) (candidates []Completion, directive CompletionDirective)
+
+// Complete returns the candidates for the last of words, which is the word being completed
+// and may be empty. The first word is the name of the program. Words split by bash at "="
+// or ":", as in "--format", "=", "j", are joined back together, and the part of the last
+// word in front of the split is cut from the candidates because bash does not replace it.
+func (tree CommandTree) Complete(words []string) (candidates []Completion, directive CompletionDirective) {
+	words, kept := joinBrokenWords(words)
+	candidates, directive = tree.complete(words)
+	if kept != "" && directive&(CompleteFiles|CompleteDirs|CompleteFileExt) == 0 {
+		for i := range candidates {
+			candidates[i].Value = strings.TrimPrefix(candidates[i].Value, kept)
+		}
+	}
+	return candidates, directive
+}
+
+// wordBreaks are the characters in the default COMP_WORDBREAKS of bash that can appear in
+// the words of a command line.
+const wordBreaks = "=:"
+
+// joinBrokenWords joins the words that bash split at wordBreaks, which it passes as words of
+// their own. kept is the part of the last joined word that comes before the word bash is
+// completing, or "" when the last word was not split.
+func joinBrokenWords(words []string) (joined []string, kept string) {
+	isBreak := func(word string) bool {
+		return word != "" && strings.Trim(word, wordBreaks) == ""
+	}
+	split := false
+	for i, word := range words {
+		switch {
+		case i > 1 && isBreak(word):
+			joined[len(joined)-1] += word
+			split = true
+		case i > 1 && isBreak(words[i-1]):
+			joined[len(joined)-1] += word
+		default:
+			joined = append(joined, word)
+			split = false
+		}
+	}
+	if split {
+		last, current := joined[len(joined)-1], words[len(words)-1]
+		if isBreak(current) {
+			current = ""
+		}
+		kept = strings.TrimSuffix(last, current)
+	}
+	return joined, kept
+}
+
+func (tree CommandTree) complete(words []string) (candidates []Completion, directive CompletionDirective) {
+	if len(words) < 2 {
+		return nil, CompleteDefault
+	}
+	prefix := words[len(words)-1]
+	preceding := append([]string{tree.Root.Name}, words[1:len(words)-1]...)
+
+	fullCom, pathToCom, preceding, rest, err := tree.findCommand(preceding)
+	if err != nil {
+		return nil, CompleteDefault
+	}
+	fullCom.Flags = append(fullCom.Flags, tree.Shared.Flags...)
+	fullCom.Args = append(fullCom.Args, tree.Shared.Args...)
+	fullCom.Opts = append(fullCom.Opts, tree.Shared.Opts...)
+	inherited := tree.inheritedBy(fullCom, pathToCom)
+	fullCom.Flags = append(fullCom.Flags, inherited.Flags...)
+	fullCom.Opts = append(fullCom.Opts, inherited.Opts...)
+
+	// The value of an option, either "--key value" or "--key=value"
+	if last := preceding[len(preceding)-1]; len(preceding) > len(pathToCom)+1 && strings.HasPrefix(last, "-") && !strings.Contains(last, "=") {
+		if opt, found := fullCom.findOption(strings.TrimLeft(last, "-")); found {
+			userCom, _ := tree.partialParse(preceding[:len(preceding)-1], fullCom, pathToCom)
+			return completeOptionValue(opt, userCom, "", prefix)
+		}
+	}
+	if strings.HasPrefix(prefix, "--") && strings.Contains(prefix, "=") {
+		keyValue := strings.SplitN(prefix, "=", 2)
+		if opt, found := fullCom.findOption(keyValue[0][2:]); found {
+			userCom, _ := tree.partialParse(preceding, fullCom, pathToCom)
+			return completeOptionValue(opt, userCom, keyValue[0]+"=", keyValue[1])
+		}
+		return nil, CompleteNoFiles
+	}
+
+	if strings.HasPrefix(prefix, "-") {
+		for _, f := range fullCom.Flags {
+			if f.LongName != "" {
+				candidates = appendMatch(candidates, Completion{"--" + f.LongName, f.Description}, prefix)
+			}
+			if f.Negatable && f.LongName != "" {
+				candidates = appendMatch(candidates, Completion{"--no-" + f.LongName, f.Description}, prefix)
+			}
+		}
+		for _, o := range fullCom.Opts {
+			if o.LongName != "" {
+				candidates = appendMatch(candidates, Completion{"--" + o.LongName, o.Description}, prefix)
+			}
+		}
+		sort.Sort(byValue(candidates))
+		return candidates, CompleteNoFiles
+	}
+
+	// Subcommands can only follow the path to the command and any persistent inputs
+	if rest == len(preceding) {
+		for _, sub := range fullCom.SubCommands {
+			candidates = appendMatch(candidates, Completion{sub.Name, sub.Description}, prefix)
+		}
+	}
+
+	userCom, numArgs := tree.partialParse(preceding, fullCom, pathToCom)
+	if def, found := completingArg(fullCom.positionalArgs(), numArgs); found {
+		for _, choice := range def.Choices {
+			candidates = appendMatch(candidates, Completion{Value: choice}, prefix)
+		}
+		if def.Complete != nil {
+			argCandidates, argDirective := def.Complete(userCom, prefix)
+			return append(candidates, argCandidates...), argDirective
+		}
+	}
+	if candidates != nil {
+		directive = CompleteNoFiles
+	}
+	return candidates, directive
+}
+
+// partialParse parses the words typed so far, ignoring any errors, and returns the result
+// along with the number of positional arguments given.
+func (tree CommandTree) partialParse(words []string, fullCom Command, pathToCom []string) (userCom Command, numArgs int) {
+	userCom, _ = parseCommandLine(words, fullCom, tree.parseSettings())
+	numArgs = len(userCom.positionalValues(fullCom))
+	resolveValues(fullCom, &userCom, nil, commandPath(pathToCom, fullCom.Name)[1:])
+	return userCom, numArgs
+}
+
+// completingArg returns the Argument of defs that the value after numArgs others fills.
+// Once a Variadic Argument is reached, every further value is completed as one of its.
+func completingArg(defs []Argument, numArgs int) (def Argument, found bool) {
+	for i, d := range defs {
+		if d.Variadic && i <= numArgs {
+			return d, true
+		}
+	}
+	if numArgs < len(defs) {
+		return defs[numArgs], true
+	}
+	return def, false
+}
+
+func completeOptionValue(opt Option, userCom Command, valPrefix string, prefix string) (candidates []Completion, directive CompletionDirective) {
+	for _, choice := range opt.Choices {
+		candidates = appendMatch(candidates, Completion{Value: valPrefix + choice}, valPrefix+prefix)
+	}
+	if opt.Complete != nil {
+		var optCandidates []Completion
+		optCandidates, directive = opt.Complete(userCom, prefix)
+		for _, c := range optCandidates {
+			if directive&CompleteFileExt == 0 {
+				c.Value = valPrefix + c.Value
+			}
+			candidates = append(candidates, c)
+		}
+		return candidates, directive
+	}
+	return candidates, CompleteNoFiles
+}
+
+func appendMatch(candidates []Completion, candidate Completion, prefix string) []Completion {
+	if strings.HasPrefix(candidate.Value, prefix) {
+		return append(candidates, candidate)
+	}
+	return candidates
+}
+
+type byValue []Completion
+
+func (b byValue) Len() int           { return len(b) }
+func (b byValue) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byValue) Less(i, j int) bool { return b[i].Value < b[j].Value }
+
+// runComplete answers a call to the hidden completion command. Each candidate is printed on
+// its own line, with a tab before its description. The last line holds the directive as
+// ":n". Shells that can not pass an empty argument send "" in its place.
+func (tree CommandTree) runComplete(appArgs []string) {
+	words := append([]string{tree.Root.Name}, appArgs[2:]...)
+	if last := len(words) - 1; words[last] == `""` {
+		words[last] = ""
+	}
+	candidates, directive := tree.Complete(words)
+	for _, c := range candidates {
+		if c.Description == "" {
+			fmt.Println(c.Value)
+		} else {
+			fmt.Printf("%s\t%s\n", c.Value, strings.Replace(c.Description, "\n", " ", -1))
+		}
+	}
+	fmt.Printf(":%d\n", directive)
+}
+
+// GenBashCompletion writes a bash completion script for the tree to w.
+func GenBashCompletion(w io.Writer, tree *CommandTree) error {
+	name := tree.Root.Name
+	_, err := fmt.Fprintf(w, `# bash completion for %[1]s
+_%[2]s_complete() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+        [[ $cur == [=:] ]] && cur=""
+    fi
+    local IFS=$'\n'
+    local out directive line ext
+    out=$("${words[0]}" %[3]s "${words[@]:1:cword}" 2>/dev/null) || return
+    directive=${out##*:}
+    out=${out%%:*}
+    COMPREPLY=()
+
+    if (( directive & %[5]d )); then
+        COMPREPLY=( $(compgen -d -- "${cur}") )
+        return
+    fi
+    if (( directive & %[6]d )); then
+        for ext in ${out}; do
+            COMPREPLY+=( $(compgen -f -X "!*.${ext}" -- "${cur}") )
+        done
+        COMPREPLY+=( $(compgen -d -- "${cur}") )
+        return
+    fi
+    if (( directive & %[4]d )); then
+        COMPREPLY=( $(compgen -f -- "${cur}") )
+        return
+    fi
+
+    for line in ${out}; do
+        COMPREPLY+=( "${line%%%%$'\t'*}" )
+    done
+    COMPREPLY=( $(compgen -W "${COMPREPLY[*]}" -- "${cur}") )
+    # Bash only replaces the part of the word after the last "=" or ":"
+    if [[ $cur == *[=:]* ]]; then
+        local kept="${cur%%"${cur##*[=:]}"}"
+        COMPREPLY=( "${COMPREPLY[@]#"$kept"}" )
+    fi
+    if (( directive & %[7]d )); then
+        compopt -o nospace
+    fi
+    if (( directive & %[8]d )) || [[ ${#COMPREPLY[@]} -gt 0 ]]; then
+        compopt +o default
+    fi
+}
+complete -o default -F _%[2]s_complete %[1]s
+`, name, shellIdentifier(name), completeCommand, CompleteFiles, CompleteDirs, CompleteFileExt, CompleteNoSpace, CompleteNoFiles)
+	return err
+}
+
+// GenZshCompletion writes a zsh completion script for the tree to w.
+func GenZshCompletion(w io.Writer, tree *CommandTree) error {
+	name := tree.Root.Name
+	_, err := fmt.Fprintf(w, `#compdef %[1]s
+_%[2]s() {
+    local -a lines completions exts
+    local out directive line value
+    out=$(${words[1]} %[3]s "${(@)words[2,$CURRENT]}" 2>/dev/null) || return
+    lines=("${(@f)out}")
+    directive=${lines[-1]#:}
+    lines=("${(@)lines[1,-2]}")
+
+    if (( directive & %[5]d )); then
+        _files -/
+        return
+    fi
+    if (( directive & %[6]d )); then
+        for line in $lines; do
+            exts+=("*.${line}")
+        done
+        _files -g "${(j: :)exts}"
+        return
+    fi
+    if (( directive & %[4]d )); then
+        _files
+        return
+    fi
+
+    for line in $lines; do
+        value=${line%%%%$'\t'*}
+        value=${value//:/\\:}
+        if [[ $line == *$'\t'* ]]; then
+            completions+=("${value}:${line#*$'\t'}")
+        else
+            completions+=("${value}")
+        fi
+    done
+    if (( directive & %[7]d )); then
+        _describe -t values 'values' completions -S ''
+    else
+        _describe -t values 'values' completions
+    fi
+    if (( ! (directive & %[8]d) )) && (( ${#completions} == 0 )); then
+        _files
+    fi
+}
+compdef _%[2]s %[1]s
+`, name, shellIdentifier(name), completeCommand, CompleteFiles, CompleteDirs, CompleteFileExt, CompleteNoSpace, CompleteNoFiles)
+	return err
+}
+
+// GenFishCompletion writes a fish completion script for the tree to w.
+func GenFishCompletion(w io.Writer, tree *CommandTree) error {
+	name := tree.Root.Name
+	_, err := fmt.Fprintf(w, `# fish completion for %[1]s
+function __%[2]s_complete
+    set -l tokens (commandline -opc)
+    set -l cur (commandline -ct)
+    set -l out ($tokens[1] %[3]s $tokens[2..-1] "$cur" 2>/dev/null)
+    or return
+    set -l directive (string replace ':' '' -- $out[-1])
+    set -e out[-1]
+
+    if test (math "bitand($directive, %[5]d)") -ne 0
+        __fish_complete_directories "$cur"
+    else if test (math "bitand($directive, %[6]d)") -ne 0
+        for ext in $out
+            __fish_complete_suffix "$cur" ".$ext"
+        end
+    else if test (math "bitand($directive, %[4]d)") -ne 0
+        __fish_complete_path "$cur"
+    else if test (count $out) -gt 0
+        printf '%%s\n' $out
+    else if test (math "bitand($directive, %[7]d)") -eq 0
+        __fish_complete_path "$cur"
+    end
+end
+complete -c %[1]s -f -a '(__%[2]s_complete)'
+`, name, shellIdentifier(name), completeCommand, CompleteFiles, CompleteDirs, CompleteFileExt, CompleteNoFiles)
+	return err
+}
+
+// GenPowerShellCompletion writes a PowerShell completion script for the tree to w. When the
+// program asks for file names, nothing is returned so PowerShell falls back to paths.
+func GenPowerShellCompletion(w io.Writer, tree *CommandTree) error {
+	name := tree.Root.Name
+	_, err := fmt.Fprintf(w, `# PowerShell completion for %[1]s
+Register-ArgumentCompleter -Native -CommandName '%[1]s' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | ForEach-Object { $_.ToString() })
+    $program = $words[0]
+    $rest = @($words | Select-Object -Skip 1)
+    if ($wordToComplete -eq '') {
+        $rest += '""'
+    }
+    $out = @(& $program %[2]s @rest 2>$null)
+    if ($out.Count -eq 0) {
+        return
+    }
+    $directive = [int]($out[-1].TrimStart(':'))
+    if ($directive -band (%[3]d -bor %[4]d -bor %[5]d)) {
+        return
+    }
+    $out | Select-Object -SkipLast 1 | ForEach-Object {
+        $value, $description = $_ -split "`+"`"+`t", 2
+        if (-not $description) {
+            $description = $value
+        }
+        [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $description)
+    }
+}
+`, name, completeCommand, CompleteFiles, CompleteFileExt, CompleteDirs)
+	return err
+}
+
+// shellIdentifier turns a program name into something usable as a shell function name.
+func shellIdentifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
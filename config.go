@@ -0,0 +1,372 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ryanuber/columnize"
+)
+
+var autoConfigOpt = Option{
+	LongName:    "config",
+	Description: "Read option values from this config file",
+}
+var autoShowConfigFlag = Flag{
+	LongName:    "show-config",
+	Description: "Show the value of each option and where it came from",
+}
+
+// configValue is a single value read from a config file along with the file it came from.
+type configValue struct {
+	Value string
+	Path  string
+}
+
+// configValues maps dotted keys such as "quick.brown.color" to the values read from all
+// config files. Keys from files loaded later replace those loaded earlier.
+type configValues map[string]configValue
+
+// loadConfig reads each of tree.ConfigFiles that exists, followed by the file given with
+// --config. The explicit file must exist.
+func (tree CommandTree) loadConfig(userCom Command) (conf configValues, err error) {
+	conf = make(configValues)
+	for _, path := range tree.ConfigFiles {
+		path = expandHome(path)
+		if _, statErr := os.Stat(path); statErr != nil {
+			continue
+		}
+		if err = conf.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if tree.AutoConfig {
+		for _, opt := range userCom.Opts {
+			if opt.LongName == autoConfigOpt.LongName {
+				if err = conf.readFile(expandHome(opt.Value)); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return conf, nil
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
+
+// lookup finds the value for the input called name on the command at comPath. The most
+// specific section wins, so "quick.brown.color" is used before "quick.color" and "color".
+func (conf configValues) lookup(comPath []string, name string) (val configValue, found bool) {
+	for i := len(comPath); i >= 0; i-- {
+		key := strings.Join(append(append([]string{}, comPath[:i]...), name), ".")
+		if val, found = conf[key]; found {
+			return val, true
+		}
+	}
+	return val, false
+}
+
+func (conf configValues) readFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.New(fmt.Sprintf("cli: Unable to read config file %s: %s", path, err.Error()))
+	}
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		values, err = parseJSONConfig(data)
+	case ".toml":
+		values, err = parseTOMLConfig(data)
+	case ".yaml", ".yml":
+		values, err = parseYAMLConfig(data)
+	case ".ini", ".cfg", ".conf":
+		values, err = parseINIConfig(data)
+	default:
+		err = errors.New("unknown format, expected .json, .toml, .yaml, or .ini")
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("cli: Config file %s: %s", path, err.Error()))
+	}
+
+	for key, val := range values {
+		conf[key] = configValue{Value: val, Path: path}
+	}
+	return nil
+}
+
+func parseJSONConfig(data []byte) (values map[string]string, err error) {
+	var doc map[string]interface{}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	values = make(map[string]string)
+	flattenJSON("", doc, values)
+	return values, nil
+}
+
+func flattenJSON(prefix string, doc map[string]interface{}, values map[string]string) {
+	for key, val := range doc {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := val.(type) {
+		case map[string]interface{}:
+			flattenJSON(key, v, values)
+		case []interface{}:
+			var items []string
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case nil:
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// parseTOMLConfig reads the subset of TOML used for settings: [tables], dotted keys, and
+// string, number, boolean, and single line array values.
+func parseTOMLConfig(data []byte) (values map[string]string, err error) {
+	return parseSectionedConfig(data, "#", parseTOMLValue)
+}
+
+// parseINIConfig reads [sections] of key = value pairs. Values may be quoted.
+func parseINIConfig(data []byte) (values map[string]string, err error) {
+	return parseSectionedConfig(data, ";#", func(raw string) (string, error) {
+		return unquote(raw), nil
+	})
+}
+
+func parseSectionedConfig(data []byte, comment string, parseValue func(raw string) (string, error)) (values map[string]string, err error) {
+	values = make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.ContainsAny(line[:1], comment) {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				return nil, errors.New(fmt.Sprintf("line %d: unterminated section", lineNum))
+			}
+			section = strings.TrimSpace(line[1:end])
+			continue
+		}
+
+		sep := strings.IndexAny(line, "=:")
+		if sep < 0 {
+			return nil, errors.New(fmt.Sprintf("line %d: expected key = value", lineNum))
+		}
+		key := unquote(strings.TrimSpace(line[:sep]))
+		if section != "" {
+			key = section + "." + key
+		}
+		val, err := parseValue(strings.TrimSpace(line[sep+1:]))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("line %d: %s", lineNum, err.Error()))
+		}
+		values[key] = val
+	}
+	return values, scanner.Err()
+}
+
+func parseTOMLValue(raw string) (string, error) {
+	raw = stripComment(raw)
+	if strings.HasPrefix(raw, "[") {
+		if !strings.HasSuffix(raw, "]") {
+			return "", errors.New("arrays must be on a single line")
+		}
+		var items []string
+		for _, item := range splitOutsideQuotes(raw[1:len(raw)-1], ',') {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, unquote(item))
+			}
+		}
+		return strings.Join(items, ","), nil
+	}
+	if strings.HasPrefix(raw, "\"") {
+		val, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", errors.New("invalid string " + raw)
+		}
+		return val, nil
+	}
+	return unquote(raw), nil
+}
+
+// parseYAMLConfig reads the subset of YAML used for settings: nested mappings, scalar
+// values, and lists of scalars in block or flow style.
+func parseYAMLConfig(data []byte) (values map[string]string, err error) {
+	type level struct {
+		indent int
+		key    string
+	}
+	values = make(map[string]string)
+	var stack []level
+	listKey := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		text := stripComment(scanner.Text())
+		line := strings.TrimSpace(text)
+		if line == "" || line == "---" {
+			continue
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+
+		if strings.HasPrefix(line, "- ") || line == "-" {
+			if listKey == "" {
+				return nil, errors.New(fmt.Sprintf("line %d: list item without a key", lineNum))
+			}
+			item := unquote(strings.TrimSpace(strings.TrimPrefix(line, "-")))
+			if values[listKey] == "" {
+				values[listKey] = item
+			} else {
+				values[listKey] += "," + item
+			}
+			continue
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+
+		sep := strings.Index(line, ":")
+		if sep < 0 {
+			return nil, errors.New(fmt.Sprintf("line %d: expected key: value", lineNum))
+		}
+		key := unquote(strings.TrimSpace(line[:sep]))
+		if len(stack) > 0 {
+			key = stack[len(stack)-1].key + "." + key
+		}
+		val := strings.TrimSpace(line[sep+1:])
+
+		listKey = ""
+		if val == "" {
+			// Either a nested mapping or a block list follows
+			stack = append(stack, level{indent, key})
+			listKey = key
+			continue
+		}
+		if strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]") {
+			var items []string
+			for _, item := range splitOutsideQuotes(val[1:len(val)-1], ',') {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, unquote(item))
+				}
+			}
+			val = strings.Join(items, ",")
+		} else {
+			val = unquote(val)
+		}
+		values[key] = val
+	}
+	return values, scanner.Err()
+}
+
+// stripComment removes a trailing # comment that is not inside quotes.
+func stripComment(line string) string {
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+func splitOutsideQuotes(str string, sep rune) (parts []string) {
+	quote := rune(0)
+	start := 0
+	for i, r := range str {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == sep:
+			parts = append(parts, str[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, str[start:])
+}
+
+func unquote(str string) string {
+	if len(str) >= 2 && (str[0] == '"' || str[0] == '\'') && str[len(str)-1] == str[0] {
+		return str[1 : len(str)-1]
+	}
+	return str
+}
+
+// ShowConfig returns a table of each Flag and Option of fullCom with the value in userCom
+// and the layer it came from.
+func ShowConfig(fullCom Command, userCom Command) string {
+	config := columnize.DefaultConfig()
+	config.Prefix = "  "
+	var rows []string
+	for _, f := range fullCom.Flags {
+		val, origin := "", "unset"
+		if uf, found := userCom.lookupFlag(f); found {
+			val, origin = strconv.FormatBool(!uf.Negated), describeSource(uf.Source, uf.Origin)
+		}
+		rows = append(rows, fmt.Sprintf("%s|%s|%s", f.displayName(), val, origin))
+	}
+	for _, o := range fullCom.Opts {
+		val, origin := "", "unset"
+		if uo, found := userCom.lookupOption(o); found {
+			val, origin = uo.Value, describeSource(uo.Source, uo.Origin)
+		}
+		if o.Repeatable {
+			var vals []string
+			for _, uo := range userCom.Opts {
+				if sameOption(uo, o) {
+					vals = append(vals, uo.Value)
+				}
+			}
+			val = strings.Join(vals, ",")
+		}
+		rows = append(rows, fmt.Sprintf("%s|%s|%s", o.displayName(), val, origin))
+	}
+	return columnize.Format(rows, config)
+}
+
+func describeSource(source Source, origin string) string {
+	if origin == "" {
+		return source.String()
+	}
+	return source.String() + " " + origin
+}
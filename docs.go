@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GenMarkdownTree writes a Markdown reference page for every command in the tree into dir,
+// along with an index.md linking to all of them. Pages are named after the path to the
+// command, e.g. "the-quick-brown.md".
+func GenMarkdownTree(tree *CommandTree, dir string) error {
+	nodes := CommandToNodeSlice(&tree.Root)
+	for _, node := range nodes {
+		var buf bytes.Buffer
+		genMarkdownPage(&buf, tree, node, markdownLink)
+		path := filepath.Join(dir, manPageName(node.PathToCom, node.Name)+".md")
+		if err := writeFile(path, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fmt.Sprintf("# %s reference\n\n", tree.Root.Name))
+	if tree.Version != "" {
+		buf.WriteString(fmt.Sprintf("Version %s\n\n", tree.Version))
+	}
+	for _, node := range nodes {
+		comPath := commandPath(node.PathToCom, node.Name)
+		buf.WriteString(fmt.Sprintf("%s* [%s](%s)", strings.Repeat("  ", node.Level), strings.Join(comPath, " "), markdownLink(comPath)))
+		if node.Description != "" {
+			buf.WriteString(" - " + markdownEscape(node.Description))
+		}
+		buf.WriteString("\n")
+	}
+	return writeFile(filepath.Join(dir, "index.md"), buf.Bytes())
+}
+
+// GenMarkdown writes the Markdown reference page for the command at node to w. Links to
+// other commands point at the files written by GenMarkdownTree.
+func GenMarkdown(w io.Writer, tree *CommandTree, node Node) error {
+	var buf bytes.Buffer
+	genMarkdownPage(&buf, tree, node, markdownLink)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func markdownLink(comPath []string) string {
+	return strings.Join(comPath, "-") + ".md"
+}
+
+func genMarkdownPage(buf *bytes.Buffer, tree *CommandTree, node Node, link func(comPath []string) string) {
+	c := withShared(node.Command, tree.Shared)
+	comPath := commandPath(node.PathToCom, c.Name)
+
+	buf.WriteString(fmt.Sprintf("<a id=\"%s\"></a>\n\n", docAnchor(comPath)))
+	buf.WriteString(fmt.Sprintf("# %s\n\n", strings.Join(comPath, " ")))
+
+	// Breadcrumbs back up the path to the root
+	var crumbs []string
+	for i := range node.PathToCom {
+		crumbs = append(crumbs, fmt.Sprintf("[%s](%s)", node.PathToCom[i], link(node.PathToCom[:i+1])))
+	}
+	crumbs = append(crumbs, c.Name)
+	buf.WriteString(strings.Join(crumbs, " › ") + "\n\n")
+
+	if c.Description != "" {
+		buf.WriteString(markdownEscape(c.Description) + "\n\n")
+	}
+	if c.Usage != "" {
+		buf.WriteString("## Usage\n\n")
+		buf.WriteString(fmt.Sprintf("```\n%s\n```\n\n", c.Usage))
+	}
+
+	if len(c.SubCommands) > 0 {
+		buf.WriteString("## SubCommands\n\n| Command | Description |\n| --- | --- |\n")
+		for _, sub := range c.SubCommands {
+			buf.WriteString(fmt.Sprintf("| [%s](%s) | %s |\n", sub.Name, link(commandPath(comPath, sub.Name)), markdownCell(sub.Description)))
+		}
+		buf.WriteString("\n")
+	}
+
+	if c.Args != nil {
+		buf.WriteString("## Arguments\n\n| Argument | Description |\n| --- | --- |\n")
+		for _, a := range c.Args {
+			name := fmt.Sprintf("`<%s>`", a.Name)
+			if a.Value != "" {
+				name = fmt.Sprintf("`%s`", a.Value)
+			}
+			buf.WriteString(fmt.Sprintf("| %s | %s |\n", name, markdownCell(a.Description)))
+		}
+		buf.WriteString("\n")
+	}
+
+	if c.Flags != nil {
+		buf.WriteString("## Flags\n\n| Short | Long | Description |\n| --- | --- | --- |\n")
+		for _, f := range c.Flags {
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s |\n", docName("-", f.ShortName), docName("--", f.helpName()), markdownCell(f.Description+envDescription(f.EnvVars))))
+		}
+		buf.WriteString("\n")
+	}
+
+	if c.Opts != nil {
+		buf.WriteString("## Options\n\n| Short | Long | Description |\n| --- | --- | --- |\n")
+		for _, o := range c.Opts {
+			buf.WriteString(fmt.Sprintf("| %s | %s | %s |\n", docName("-", o.ShortName), docName("--", o.LongName), markdownCell(optionDescription(o))))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+// GenHTML writes a single HTML page documenting every command in the tree to w. Each
+// command has an anchor named after the path to it, e.g. "#the-quick-brown".
+func GenHTML(w io.Writer, tree *CommandTree) error {
+	type crumb struct {
+		Name   string
+		Anchor string
+	}
+	type page struct {
+		Command
+		Anchor string
+		Path   string
+		Crumbs []crumb
+		Level  int
+	}
+
+	var pages []page
+	for _, node := range CommandToNodeSlice(&tree.Root) {
+		c := withShared(node.Command, tree.Shared)
+		var crumbs []crumb
+		for i := range node.PathToCom {
+			crumbs = append(crumbs, crumb{node.PathToCom[i], docAnchor(node.PathToCom[:i+1])})
+		}
+		comPath := commandPath(node.PathToCom, c.Name)
+		pages = append(pages, page{c, docAnchor(comPath), strings.Join(comPath, " "), crumbs, node.Level})
+	}
+
+	return htmlTemplate.Execute(w, struct {
+		Tree  *CommandTree
+		Pages []page
+	}{tree, pages})
+}
+
+var htmlTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"anchor": func(pathToCom string, name string) string {
+		return docAnchor(append(strings.Fields(pathToCom), name))
+	},
+	"indent": func(level int) string {
+		return fmt.Sprintf("%dem", level*2)
+	},
+	"optionDescription": optionDescription,
+	"envDescription":    envDescription,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Tree.Root.Name}} reference</title>
+</head>
+<body>
+<h1>{{.Tree.Root.Name}} reference</h1>
+{{with .Tree.Version}}<p>Version {{.}}</p>
+{{end}}<ul>
+{{range .Pages}}<li style="margin-left: {{indent .Level}}"><a href="#{{.Anchor}}">{{.Path}}</a>{{with .Description}} - {{.}}{{end}}</li>
+{{end}}</ul>
+{{range $page := .Pages}}
+<section id="{{.Anchor}}">
+<h2>{{.Path}}</h2>
+<p>{{range .Crumbs}}<a href="#{{.Anchor}}">{{.Name}}</a> › {{end}}{{.Name}}</p>
+{{with .Description}}<p>{{.}}</p>
+{{end}}{{with .Usage}}<pre>{{.}}</pre>
+{{end}}{{if .SubCommands}}<h3>SubCommands</h3>
+<table>
+<tr><th>Command</th><th>Description</th></tr>
+{{range .SubCommands}}<tr><td><a href="#{{anchor $page.Path .Name}}">{{.Name}}</a></td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Args}}<h3>Arguments</h3>
+<table>
+<tr><th>Argument</th><th>Description</th></tr>
+{{range .Args}}<tr><td><code>{{if .Value}}{{.Value}}{{else}}&lt;{{.Name}}&gt;{{end}}</code></td><td>{{.Description}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Flags}}<h3>Flags</h3>
+<table>
+<tr><th>Short</th><th>Long</th><th>Description</th></tr>
+{{range .Flags}}<tr><td>{{with .ShortName}}<code>-{{.}}</code>{{end}}</td><td>{{with .LongName}}<code>--{{.}}</code>{{end}}</td><td>{{.Description}}{{envDescription .EnvVars}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Opts}}<h3>Options</h3>
+<table>
+<tr><th>Short</th><th>Long</th><th>Description</th></tr>
+{{range .Opts}}<tr><td>{{with .ShortName}}<code>-{{.}}</code>{{end}}</td><td>{{with .LongName}}<code>--{{.}}</code>{{end}}</td><td>{{optionDescription .}}</td></tr>
+{{end}}</table>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+// withShared returns a copy of c with the shared parameters of the tree added to it. A
+// slice that is empty on both stays nil, so that the sections for it are left out.
+func withShared(c Command, shared SharedParameters) Command {
+	if len(c.Flags)+len(shared.Flags) > 0 {
+		c.Flags = append(append([]Flag{}, c.Flags...), shared.Flags...)
+	}
+	if len(c.Args)+len(shared.Args) > 0 {
+		c.Args = append(append([]Argument{}, c.Args...), shared.Args...)
+	}
+	if len(c.Opts)+len(shared.Opts) > 0 {
+		c.Opts = append(append([]Option{}, c.Opts...), shared.Opts...)
+	}
+	return c
+}
+
+func docAnchor(comPath []string) string {
+	return strings.ToLower(strings.Join(comPath, "-"))
+}
+
+func docName(dashes string, name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf("`%s%s`", dashes, name)
+}
+
+func markdownEscape(text string) string {
+	return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(markdownEscape(strings.TrimSpace(text)))
+}
+
+func writeFile(path string, data []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -6,12 +6,92 @@ type Flag struct {
 	ShortName   string
 	LongName    string
 	Description string
+
+	// Default sets the flag when the user does not give it. Source records where a parsed
+	// flag came from.
+	Default bool
+	Source  Source
+
+	// EnvVars are checked in order when the flag is not on the command line. A value such
+	// as true or 1 sets the flag and false or 0 turns it off. Origin holds the variable a
+	// parsed flag was read from.
+	EnvVars []string
+	Origin  string
+
+	// Count is set on a parsed flag to the number of times it was given, so that "-vvv"
+	// has a Count of 3.
+	Count int
+
+	// Negatable lets the user turn the flag off with "--no-" followed by its long name. Any
+	// flag can also be given an explicit value, as in "--color=false". Negated is set on a
+	// parsed flag that was turned off, so that a flag is unset when it has no entry in the
+	// parsed Flags, and otherwise on or off.
+	Negatable bool
+	Negated   bool
+
+	// Persistent makes every command below the one defining the flag inherit it. The user
+	// may also give it before the names of those commands, as in "the --verbose quick".
+	Persistent bool
 }
 
 func (flag *Flag) String() string {
 	return fmt.Sprintf("Flg: -%-2s --%-10s, %-s", flag.ShortName, flag.LongName, flag.Description)
 }
 
+// name returns whichever of the long or short name is set, preferring the long name.
+func (flag *Flag) name() string {
+	if flag.LongName != "" {
+		return flag.LongName
+	}
+	return flag.ShortName
+}
+
+// displayName returns the name of the flag as the user would type it.
+func (flag *Flag) displayName() string {
+	if flag.LongName != "" {
+		return "--" + flag.LongName
+	}
+	return "-" + flag.ShortName
+}
+
+// helpName returns the long name of the flag as shown in help, e.g. "[no-]color" when it
+// is Negatable.
+func (flag *Flag) helpName() string {
+	if flag.Negatable && flag.LongName != "" {
+		return "[no-]" + flag.LongName
+	}
+	return flag.LongName
+}
+
+// addFlag records an occurrence of the flag f given by the user in userCom. A flag given
+// again counts up the entry that is already there, and the last occurrence decides
+// whether it is on or off. Turning a flag off resets its Count.
+func addFlag(userCom *Command, def Flag, f Flag) {
+	for i := range userCom.Flags {
+		if !sameFlag(userCom.Flags[i], def) {
+			continue
+		}
+		prev := &userCom.Flags[i]
+		if f.Negated || prev.Negated {
+			prev.Count = 0
+		}
+		if !f.Negated {
+			prev.Count++
+		}
+		prev.Negated = f.Negated
+		return
+	}
+	if !f.Negated {
+		f.Count = 1
+	}
+	userCom.Flags = append(userCom.Flags, f)
+}
+
+// sameFlag reports whether the parsed flag f is an entry for the definition def.
+func sameFlag(f Flag, def Flag) bool {
+	return (def.ShortName != "" && f.ShortName == def.ShortName) || (def.LongName != "" && f.LongName == def.LongName)
+}
+
 func FlagArrayToStringArray(flagArr []Flag) (strArr []string) {
 	for _, flag := range flagArr {
 		strArr = append(strArr, flag.String())
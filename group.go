@@ -0,0 +1,139 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GroupKind is the constraint a Group places on its members.
+type GroupKind int
+
+const (
+	GroupMutuallyExclusive GroupKind = iota // at most one member may be given
+	GroupAtLeastOne                         // one or more members must be given
+	GroupExactlyOne                         // one member must be given
+	GroupAllOrNone                          // every member or none of them must be given
+	GroupRequires                           // the first member requires the rest
+)
+
+// A Group constrains which of the Flags, Options, and Arguments of a command may be given
+// together. Members are named by the short or long name of a Flag or Option, or the Name
+// of an Argument. A member counts as given when it has a value from any source other than
+// its default, and a flag only when it is on.
+type Group struct {
+	Kind    GroupKind
+	Members []string
+}
+
+// CheckGroups verifies that userCom meets every Group of fullCom. The returned error names
+// the inputs that break the first Group that is not met.
+func CheckGroups(fullCom Command, userCom Command) error {
+	for _, g := range fullCom.Groups {
+		var given, missing []string
+		for _, m := range g.Members {
+			if userCom.provided(m) {
+				given = append(given, fullCom.inputName(m))
+			} else {
+				missing = append(missing, fullCom.inputName(m))
+			}
+		}
+
+		errStr := ""
+		switch g.Kind {
+		case GroupMutuallyExclusive:
+			if len(given) > 1 {
+				errStr = fmt.Sprintf("cli: %s can not be used together", joinAnd(given))
+			}
+		case GroupAtLeastOne:
+			if given == nil {
+				errStr = fmt.Sprintf("cli: At least one of %s is required", strings.Join(missing, ", "))
+			}
+		case GroupExactlyOne:
+			if given == nil {
+				errStr = fmt.Sprintf("cli: Exactly one of %s is required", strings.Join(missing, ", "))
+			} else if len(given) > 1 {
+				errStr = fmt.Sprintf("cli: %s can not be used together", joinAnd(given))
+			}
+		case GroupAllOrNone:
+			if given != nil && missing != nil {
+				errStr = fmt.Sprintf("cli: %s must be used together, missing %s", joinAnd(fullCom.inputNames(g.Members)), joinAnd(missing))
+			}
+		case GroupRequires:
+			if len(g.Members) > 0 && userCom.provided(g.Members[0]) && missing != nil {
+				errStr = fmt.Sprintf("cli: %s requires %s", fullCom.inputName(g.Members[0]), joinAnd(missing))
+			}
+		}
+		if errStr != "" {
+			return errors.New(errStr)
+		}
+	}
+	return nil
+}
+
+// describe returns the constraint of the group as shown in help.
+func (g Group) describe(c Command) string {
+	switch g.Kind {
+	case GroupMutuallyExclusive:
+		return "at most one may be given"
+	case GroupAtLeastOne:
+		return "at least one is required"
+	case GroupExactlyOne:
+		return "exactly one is required"
+	case GroupAllOrNone:
+		return "all or none must be given"
+	case GroupRequires:
+		if len(g.Members) > 1 {
+			return fmt.Sprintf("%s requires %s", c.inputName(g.Members[0]), joinAnd(c.inputNames(g.Members[1:])))
+		}
+	}
+	return ""
+}
+
+// provided reports whether a parsed Command has a value other than its default for the
+// flag, option, or argument called name. A flag that was turned off is not provided.
+func (c Command) provided(name string) bool {
+	for _, f := range c.parsedFlags(name) {
+		if !f.Negated && f.Source != SourceDefault {
+			return true
+		}
+	}
+	for _, o := range c.parsedOptions(name) {
+		if o.Source != SourceDefault {
+			return true
+		}
+	}
+	for _, a := range c.Args {
+		if a.Name == name && a.Source != SourceDefault {
+			return true
+		}
+	}
+	return false
+}
+
+// inputName returns the flag, option, or argument of c called name as the user would see
+// it, e.g. "--file" or "<src>".
+func (c Command) inputName(name string) string {
+	if f, found := c.findFlag(name); found {
+		return f.displayName()
+	}
+	if o, found := c.findOption(name); found {
+		return o.displayName()
+	}
+	return "<" + name + ">"
+}
+
+func (c Command) inputNames(names []string) (inputs []string) {
+	for _, name := range names {
+		inputs = append(inputs, c.inputName(name))
+	}
+	return inputs
+}
+
+// joinAnd joins strs as in "--a, --b and --c".
+func joinAnd(strs []string) string {
+	if len(strs) < 2 {
+		return strings.Join(strs, "")
+	}
+	return strings.Join(strs[:len(strs)-1], ", ") + " and " + strs[len(strs)-1]
+}
@@ -3,59 +3,262 @@ package cli
 import (
 	"bytes"
 	"fmt"
-
-	"github.com/ryanuber/columnize"
+	"strings"
+	"text/template"
 )
 
+// DefaultHelpTemplate renders the help for a command. It is executed with a HelpData and
+// the functions in HelpFuncs. Set HelpTemplate on a CommandTree or Command to replace it.
+const DefaultHelpTemplate = `{{wrap .Width (print .Command.Name ": " .Command.Description)}}
+Usage: {{wrap .Width .Command.Usage}}
+{{range argSetUsages .Path .Command}}       {{.}}
+{{end}}
+{{if .Command.SubCommands}} SubCommands:
+{{columnize .Width (subCommandRows .Command.SubCommands)}}
+
+{{end}}{{if .Command.Args}} Arguments:
+{{columnize .Width (argRows .Command.Args)}}
+
+{{end}}{{if .Command.Flags}} Flags:
+{{columnize .Width (flagRows .Command.Flags)}}
+
+{{end}}{{if .Command.Opts}} Options:
+{{columnize .Width (optionRows .Command.Opts)}}
+
+{{end}}{{if or .InheritedFlags .InheritedOpts}} Inherited options:
+{{columnize .Width (inheritedRows .InheritedFlags .InheritedOpts)}}
+
+{{end}}{{if .Command.Groups}} Groups:
+{{columnize .Width (groupRows .Command)}}
+
+{{end}}`
+
+// HelpData is the data model that help templates are executed with.
+type HelpData struct {
+	Command   Command          // the command help is shown for, including shared inputs
+	PathToCom []string         // the path from the root to the command, not including it
+	Path      string           // the full path to the command, e.g. "the quick brown"
+	Shared    SharedParameters // the parameters every command in the tree inherits
+	Tree      *CommandTree     // the tree the command belongs to, nil from ToHelpString
+	Width     int              // the width of the terminal from TerminalWidth
+
+	// The Persistent flags and options the command inherits from the commands above it.
+	// They are only known from CommandTree.HelpString.
+	InheritedFlags []Flag
+	InheritedOpts  []Option
+}
+
+// HelpFuncs are the functions available inside help templates.
+//
+//	columnize width rows
+//	                  aligns rows of "|" separated columns under a two space prefix,
+//	                  wrapping the last column to fit in width
+//	wrap width text   wraps text into lines no longer than width
+//	indent n text     indents every line of text by n spaces
+//	join sep strs     joins strs with sep
+//	argSetUsages path com
+//	                  one usage line per positional signature when com has ArgSets
+//	subCommandRows, argRows, flagRows, optionRows, groupRows
+//	                  turn the inputs of a command into rows for columnize
+//	inheritedRows flags opts
+//	                  turns inherited flags and options into rows for columnize
+var HelpFuncs = template.FuncMap{
+	"columnize":      formatColumns,
+	"wrap":           wrapText,
+	"indent":         indentText,
+	"join":           func(sep string, strs []string) string { return strings.Join(strs, sep) },
+	"argSetUsages":   argSetUsages,
+	"subCommandRows": subCommandRows,
+	"argRows":        argRows,
+	"flagRows":       flagRows,
+	"optionRows":     optionRows,
+	"groupRows":      groupRows,
+	"inheritedRows":  inheritedRows,
+}
+
+// ToHelpString renders the help for c with its own HelpTemplate or, when it has none,
+// DefaultHelpTemplate.
 func ToHelpString(c Command, pathToCom []string) (help string) {
+	return renderHelp(c.HelpTemplate, newHelpData(c, pathToCom, nil))
+}
+
+// HelpString renders the help for c. The template of the command takes precedence over
+// the template of the tree, which takes precedence over DefaultHelpTemplate.
+func (tree *CommandTree) HelpString(c Command, pathToCom []string) (help string) {
+	text := c.HelpTemplate
+	if text == "" {
+		text = tree.HelpTemplate
+	}
+	return renderHelp(text, newHelpData(c, pathToCom, tree))
+}
+
+func newHelpData(c Command, pathToCom []string, tree *CommandTree) (data HelpData) {
+	data.Command = c
+	data.PathToCom = pathToCom
+	data.Path = strings.Join(commandPath(pathToCom, c.Name), " ")
+	data.Tree = tree
+	data.Width = TerminalWidth()
+	if tree != nil {
+		data.Shared = tree.Shared
+		// Inherited inputs are read from the environment under the path of this command.
+		inherited := tree.inheritedBy(c, pathToCom)
+		inherited.Name = c.Name
+		tree.bindEnvVars(&inherited, pathToCom)
+		data.InheritedFlags = inherited.Flags
+		data.InheritedOpts = inherited.Opts
+	}
+	return data
+}
+
+func renderHelp(text string, data HelpData) string {
+	if text == "" {
+		text = DefaultHelpTemplate
+	}
+	tmpl, err := template.New("help").Funcs(HelpFuncs).Parse(text)
+	if err != nil {
+		return fmt.Sprintf("cli: Invalid help template: %s", err.Error())
+	}
 	var helpBuf bytes.Buffer
-	config := columnize.DefaultConfig()
-	config.Prefix = "  "
+	if err = tmpl.Execute(&helpBuf, data); err != nil {
+		return fmt.Sprintf("cli: Unable to render help: %s", err.Error())
+	}
+	return helpBuf.String()
+}
 
-	helpBuf.WriteString(fmt.Sprintf("%s: %s\n", c.Name, c.Description))
-	helpBuf.WriteString(fmt.Sprintf("Usage: %s\n\n", c.Usage))
+func argSetUsages(path string, c Command) []string {
+	if c.ArgSets == nil {
+		return nil
+	}
+	return c.argUsages(path)
+}
 
-	if c.SubCommands != nil {
-		helpBuf.WriteString(" SubCommands:\n")
-		var subs []string
-		for _, s := range c.SubCommands {
-			subs = append(subs, fmt.Sprintf("%s:|%s", s.Name, s.Description))
+// formatColumns aligns rows of "|" separated columns the same way columnize does, with a
+// two space prefix. When a row is wider than width, its last column is wrapped with a
+// hanging indent so that it stays under the column it started in.
+func formatColumns(width int, rows []string) string {
+	const prefix, glue = "  ", "  "
+
+	var table [][]string
+	var widths []int
+	for _, row := range rows {
+		var elems []string
+		for i, field := range strings.Split(row, "|") {
+			field = strings.TrimSpace(field)
+			elems = append(elems, field)
+			if len(widths) <= i {
+				widths = append(widths, len(field))
+			} else if widths[i] < len(field) {
+				widths[i] = len(field)
+			}
 		}
-		helpBuf.WriteString(columnize.Format(subs, config))
-		helpBuf.WriteString("\n\n")
+		table = append(table, elems)
 	}
 
-	if c.Args != nil {
-		helpBuf.WriteString(" Arguments:\n")
-		var args []string
-		for _, a := range c.Args {
-			args = append(args, fmt.Sprintf("<%s>|%s", a.Name, a.Description))
+	var lines []string
+	for _, elems := range table {
+		last := len(elems) - 1
+		line := prefix
+		for i := 0; i < last; i++ {
+			line += fmt.Sprintf("%-*s%s", widths[i], elems[i], glue)
+		}
+		// Wrapping into a very narrow column would be harder to read than overflowing
+		hang := len(line)
+		if hang+len(elems[last]) > width && width-hang >= 10 {
+			line += indentText(hang, wrapText(width-hang, elems[last]))[hang:]
+		} else {
+			line += elems[last]
 		}
-		helpBuf.WriteString(columnize.Format(args, config))
-		helpBuf.WriteString("\n\n")
+		lines = append(lines, line)
 	}
+	return strings.Join(lines, "\n")
+}
 
-	if c.Flags != nil {
-		helpBuf.WriteString(" Flags:\n")
-		var flags []string
-		for _, f := range c.Flags {
-			flags = append(flags, toShortLongDescString(f.ShortName, f.LongName, f.Description))
+// wrapText breaks text on spaces into lines no longer than width. Text that already fits is
+// returned as it is.
+func wrapText(width int, text string) string {
+	fits := true
+	for _, line := range strings.Split(text, "\n") {
+		fits = fits && len(line) <= width
+	}
+	if fits {
+		return text
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line == "" {
+				line = word
+			} else {
+				line += " " + word
+			}
 		}
-		helpBuf.WriteString(columnize.Format(flags, config))
-		helpBuf.WriteString("\n\n")
+		lines = append(lines, line)
 	}
+	return strings.Join(lines, "\n")
+}
 
-	if c.Opts != nil {
-		helpBuf.WriteString(" Options:\n")
-		var opts []string
-		for _, o := range c.Opts {
-			opts = append(opts, toShortLongDescString(o.ShortName, o.LongName, o.Description))
+func indentText(n int, text string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.Replace(text, "\n", "\n"+pad, -1)
+}
+
+func subCommandRows(subs []Command) (rows []string) {
+	for _, s := range subs {
+		name := s.Name
+		if s.Aliases != nil {
+			name += fmt.Sprintf(" (%s)", strings.Join(s.Aliases, ", "))
 		}
-		helpBuf.WriteString(columnize.Format(opts, config))
-		helpBuf.WriteString("\n\n")
+		rows = append(rows, fmt.Sprintf("%s:|%s", name, s.Description))
 	}
-	help = helpBuf.String()
-	return
+	return rows
+}
+
+func argRows(args []Argument) (rows []string) {
+	for _, a := range args {
+		label := a.label()
+		if a.Choices != nil {
+			label += " " + choicesString(a.Choices)
+		}
+		rows = append(rows, fmt.Sprintf("%s|%s", label, a.Description))
+	}
+	return rows
+}
+
+func flagRows(flags []Flag) (rows []string) {
+	for _, f := range flags {
+		rows = append(rows, toShortLongDescString(f.ShortName, f.helpName(), f.Description+envDescription(f.EnvVars)))
+	}
+	return rows
+}
+
+func optionRows(opts []Option) (rows []string) {
+	for _, o := range opts {
+		short, long := o.ShortName, o.LongName
+		if o.Choices != nil && long != "" {
+			long += " " + choicesString(o.Choices)
+		} else if o.Choices != nil {
+			short += " " + choicesString(o.Choices)
+		}
+		rows = append(rows, toShortLongDescString(short, long, optionDescription(o)))
+	}
+	return rows
+}
+
+func inheritedRows(flags []Flag, opts []Option) (rows []string) {
+	return append(flagRows(flags), optionRows(opts)...)
+}
+
+func groupRows(c Command) (rows []string) {
+	for _, g := range c.Groups {
+		rows = append(rows, fmt.Sprintf("%s|%s", strings.Join(c.inputNames(g.Members), ", "), g.describe(c)))
+	}
+	return rows
 }
 
 func toShortLongDescString(short string, long string, description string) (str string) {
@@ -71,3 +274,20 @@ func toShortLongDescString(short string, long string, description string) (str s
 	str = buf.String()
 	return
 }
+
+// optionDescription returns the description of an option followed by its default value
+// and the environment variables it can be read from.
+func optionDescription(o Option) string {
+	desc := o.Description
+	if o.Default != "" {
+		desc += fmt.Sprintf(" (default: %s)", o.Default)
+	}
+	return desc + envDescription(o.EnvVars)
+}
+
+func envDescription(envVars []string) string {
+	if envVars == nil {
+		return ""
+	}
+	return fmt.Sprintf(" [env: %s]", strings.Join(envVars, ", "))
+}
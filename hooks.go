@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ActionFunc is the type of the Action, PreAction, and PostAction of a command.
+type ActionFunc func(com Command) error
+
+// ContextActionFunc is the type of the ContextAction of a command. ctx is the same as
+// com.Context().
+type ContextActionFunc func(ctx context.Context, com Command) error
+
+// Middleware wraps an Action, e.g. for logging, timing, authorization, or recovering from
+// a panic. It is given the next ActionFunc in the chain and returns one that calls it, or
+// returns an error without calling it to stop the Action from running.
+type Middleware func(next ActionFunc) ActionFunc
+
+// hookLevel holds the hooks of the shared parameters or of one command on the path.
+type hookLevel struct {
+	pre  ActionFunc
+	post ActionFunc
+}
+
+// runActions runs the PreActions from the shared parameters and the root down to fullCom,
+// the Action of fullCom wrapped in every Middleware, and then the PostActions from fullCom
+// back up to the root and the shared parameters. A failing PreAction stops the ones after
+// it and the Action, but every PostAction whose PreAction was reached still runs and can
+// inspect the failure through com.Err.
+func (tree CommandTree) runActions(fullCom Command, userCom Command, pathToCom []string) (err error) {
+	comPath := commandPath(pathToCom, fullCom.Name)
+
+	levels := []hookLevel{{tree.Shared.PreAction, tree.Shared.PostAction}}
+	middleware := append([]Middleware{}, tree.Shared.Middleware...)
+	for _, com := range tree.commandsOn(pathToCom) {
+		levels = append(levels, hookLevel{com.PreAction, com.PostAction})
+		middleware = append(middleware, com.Middleware...)
+	}
+	levels = append(levels, hookLevel{fullCom.PreAction, fullCom.PostAction})
+	middleware = append(middleware, fullCom.Middleware...)
+
+	reached := 0
+	for _, level := range levels {
+		reached++
+		if level.pre != nil {
+			err = wrapActionError(comPath, "PreAction", level.pre(userCom))
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	action := fullCom.Action
+	if contextAction := fullCom.ContextAction; contextAction != nil {
+		action = func(com Command) error {
+			return contextAction(com.Context(), com)
+		}
+	}
+	if err == nil && action != nil {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			action = middleware[i](action)
+		}
+		err = wrapActionError(comPath, "Action", action(userCom))
+	}
+
+	for i := reached - 1; i >= 0; i-- {
+		if levels[i].post == nil {
+			continue
+		}
+		userCom.Err = err
+		postErr := levels[i].post(userCom)
+		if err == nil {
+			err = wrapActionError(comPath, "PostAction", postErr)
+		}
+	}
+	return err
+}
+
+// notifySignals and stopSignals start and stop the delivery of the signals that cancel the
+// context of RunContext. Tests replace them to deliver signals without raising them.
+var (
+	notifySignals = func(c chan<- os.Signal) { signal.Notify(c, os.Interrupt, syscall.SIGTERM) }
+	stopSignals   = signal.Stop
+)
+
+// handleSignals returns a context derived from parent that is cancelled on the first
+// signal. A second signal, or the end of tree.GracePeriod, exits the program. stop releases
+// the signals and the context.
+func (tree *CommandTree) handleSignals(parent context.Context) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(parent)
+	sigs := make(chan os.Signal, 2)
+	done := make(chan struct{})
+	gracePeriod, exit := tree.GracePeriod, tree.exit()
+	notifySignals(sigs)
+
+	go func() {
+		var sig os.Signal
+		select {
+		case sig = <-sigs:
+			cancel()
+		case <-done:
+			return
+		}
+
+		var timeout <-chan time.Time
+		if gracePeriod > 0 {
+			timer := time.NewTimer(gracePeriod)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case sig = <-sigs:
+		case <-timeout:
+		case <-done:
+			return
+		}
+		exit(signalExitCode(sig))
+	}()
+
+	stop = func() {
+		stopSignals(sigs)
+		close(done)
+		cancel()
+	}
+	return ctx, stop
+}
+
+// exit returns the function that ends the program with a signal's exit code.
+func (tree *CommandTree) exit() func(code int) {
+	if tree.Exit != nil {
+		return tree.Exit
+	}
+	return os.Exit
+}
+
+// signalExitCode returns the exit code shells use for a program killed by sig.
+func signalExitCode(sig os.Signal) int {
+	if sig == syscall.SIGTERM {
+		return 143
+	}
+	return 130
+}
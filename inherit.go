@@ -0,0 +1,138 @@
+package cli
+
+import "strings"
+
+// inheritedBy returns the Persistent flags and options that c inherits from the commands
+// on pathToCom. An input that c defines itself under the same name is left out, as is one
+// defined again by a command further down the path.
+func (tree CommandTree) inheritedBy(c Command, pathToCom []string) (inherited Command) {
+	ancestors := tree.commandsOn(pathToCom)
+
+	// Walking up from the nearest ancestor lets the closest definition of a name win.
+	defined := Command{Flags: append([]Flag{}, c.Flags...), Opts: append([]Option{}, c.Opts...)}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		for _, f := range ancestors[i].Flags {
+			if f.Persistent && !defined.definesFlag(f) {
+				inherited.Flags = append(inherited.Flags, f)
+				defined.Flags = append(defined.Flags, f)
+			}
+		}
+		for _, o := range ancestors[i].Opts {
+			if o.Persistent && !defined.definesOption(o) {
+				inherited.Opts = append(inherited.Opts, o)
+				defined.Opts = append(defined.Opts, o)
+			}
+		}
+	}
+	return inherited
+}
+
+// commandsOn returns the commands named by pathToCom, from the root down.
+func (tree CommandTree) commandsOn(pathToCom []string) (coms []Command) {
+	com := &tree.Root
+	for i, name := range pathToCom {
+		if i > 0 {
+			com = subCommandNamed(com, name)
+			if com == nil {
+				break
+			}
+		}
+		coms = append(coms, *com)
+	}
+	return coms
+}
+
+func subCommandNamed(com *Command, name string) *Command {
+	for i := range com.SubCommands {
+		if com.SubCommands[i].Name == name {
+			return &com.SubCommands[i]
+		}
+	}
+	return nil
+}
+
+// definesFlag reports whether the definition c has a flag sharing a name with f.
+func (c Command) definesFlag(f Flag) bool {
+	return (f.ShortName != "" && c.hasFlag(f.ShortName)) || (f.LongName != "" && c.hasFlag(f.LongName))
+}
+
+// definesOption reports whether the definition c has an option sharing a name with o.
+func (c Command) definesOption(o Option) bool {
+	return (o.ShortName != "" && c.hasOption(o.ShortName)) || (o.LongName != "" && c.hasOption(o.LongName))
+}
+
+// addPersistent adds the Persistent flags and options of com to c.
+func (c *Command) addPersistent(com Command) {
+	for _, f := range com.Flags {
+		if f.Persistent {
+			c.Flags = append(c.Flags, f)
+		}
+	}
+	for _, o := range com.Opts {
+		if o.Persistent {
+			c.Opts = append(c.Opts, o)
+		}
+	}
+}
+
+// expandToken replaces a long name in token that is a prefix of the long name of one of
+// the inputs of c with that name, keeping any "=value" after it.
+func (c Command) expandToken(token string) (string, error) {
+	if token == "--" || !strings.HasPrefix(token, "--") {
+		return token, nil
+	}
+	name, value := token[2:], ""
+	if eq := strings.Index(name, "="); eq >= 0 {
+		name, value = name[:eq], name[eq:]
+	}
+	long, err := c.expandLongName(name)
+	if err != nil {
+		return token, err
+	}
+	return "--" + long + value, nil
+}
+
+// inputTokens returns how many of tokens are taken by the flag or option of c given in
+// tokens[0], counting the value of an option when it is the next token. It returns 0 when
+// tokens[0] is not one of them.
+func (c Command) inputTokens(tokens []string) int {
+	token := tokens[0]
+	if token == "--" || !strings.HasPrefix(token, "-") {
+		return 0
+	}
+
+	if strings.HasPrefix(token, "--") {
+		name, hasValue := token[2:], false
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, hasValue = name[:eq], true
+		}
+		if _, found := c.findNegatedFlag(name); found || c.hasFlag(name) {
+			return 1
+		}
+		if c.hasOption(name) {
+			if hasValue || len(tokens) == 1 {
+				return 1
+			}
+			return 2
+		}
+		return 0
+	}
+
+	cluster := token[1:]
+	if cluster == "" {
+		return 0
+	}
+	for j, char := range cluster {
+		if c.hasFlag(string(char)) {
+			continue
+		}
+		if c.hasOption(string(char)) {
+			if j+len(string(char)) < len(cluster) || len(tokens) == 1 {
+				return 1
+			}
+			return 2
+		}
+		return 0
+	}
+	return 1
+}
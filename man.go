@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// GenManTree writes a man page for every command in the tree into dir, one file per command
+// named after the path to it, e.g. "the-quick-brown.1".
+func GenManTree(tree *CommandTree, dir string) error {
+	for _, node := range CommandToNodeSlice(&tree.Root) {
+		var buf bytes.Buffer
+		if err := GenManPage(&buf, tree, node); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(dir, manPageName(node.PathToCom, node.Name)+".1"), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenManPage writes the roff man page for the command at node to w. The shared parameters
+// of the tree are listed along with the command's own.
+func GenManPage(w io.Writer, tree *CommandTree, node Node) error {
+	c := withShared(node.Command, tree.Shared)
+
+	name := manPageName(node.PathToCom, c.Name)
+	comPath := strings.Join(commandPath(node.PathToCom, c.Name), " ")
+	var buf bytes.Buffer
+
+	source := tree.Root.Name
+	if tree.Version != "" {
+		source += " " + tree.Version
+	}
+	buf.WriteString(fmt.Sprintf(".TH \"%s\" \"1\" \"\" \"%s\" \"%s Manual\"\n",
+		roffEscape(strings.ToUpper(name)), roffEscape(source), roffEscape(tree.Root.Name)))
+
+	buf.WriteString(".SH NAME\n")
+	buf.WriteString(fmt.Sprintf("%s \\- %s\n", roffEscape(name), roffEscape(c.Description)))
+
+	buf.WriteString(".SH SYNOPSIS\n")
+	buf.WriteString(fmt.Sprintf("\\fB%s\\fR", roffEscape(comPath)))
+	for _, f := range c.Flags {
+		buf.WriteString(fmt.Sprintf(" [\\fB%s\\fR]", roffEscape(f.displayName())))
+	}
+	for _, o := range c.Opts {
+		buf.WriteString(fmt.Sprintf(" [\\fB%s\\fR \\fIvalue\\fR]", roffEscape(o.displayName())))
+	}
+	for _, a := range c.positionalArgs() {
+		buf.WriteString(fmt.Sprintf(" \\fI%s\\fR", roffEscape(a.Name)))
+		if a.Variadic {
+			buf.WriteString("...")
+		}
+	}
+	buf.WriteString("\n")
+	if c.Usage != "" {
+		buf.WriteString(".PP\n")
+		buf.WriteString(roffEscape(c.Usage) + "\n")
+	}
+
+	if c.Description != "" {
+		buf.WriteString(".SH DESCRIPTION\n")
+		buf.WriteString(roffEscape(c.Description) + "\n")
+	}
+
+	if len(c.Flags) > 0 || len(c.Opts) > 0 {
+		buf.WriteString(".SH OPTIONS\n")
+		for _, f := range c.Flags {
+			buf.WriteString(".TP\n")
+			buf.WriteString(fmt.Sprintf("\\fB%s\\fR\n", roffEscape(manNames(f.ShortName, f.helpName()))))
+			writeRoffText(&buf, f.Description+envDescription(f.EnvVars))
+		}
+		for _, o := range c.Opts {
+			buf.WriteString(".TP\n")
+			buf.WriteString(fmt.Sprintf("\\fB%s\\fR \\fIvalue\\fR\n", roffEscape(manNames(o.ShortName, o.LongName))))
+			writeRoffText(&buf, optionDescription(o))
+		}
+	}
+
+	if len(c.Args) > 0 {
+		buf.WriteString(".SH ARGUMENTS\n")
+		for _, a := range c.Args {
+			buf.WriteString(".TP\n")
+			if a.Value != "" {
+				buf.WriteString(fmt.Sprintf("\\fB%s\\fR\n", roffEscape(a.Value)))
+			} else {
+				buf.WriteString(fmt.Sprintf("\\fI%s\\fR\n", roffEscape(a.Name)))
+			}
+			writeRoffText(&buf, a.Description)
+		}
+	}
+
+	var seeAlso []string
+	if len(node.PathToCom) > 0 {
+		parentPath := node.PathToCom[:len(node.PathToCom)-1]
+		seeAlso = append(seeAlso, manPageName(parentPath, node.PathToCom[len(node.PathToCom)-1]))
+	}
+	for _, sub := range c.SubCommands {
+		seeAlso = append(seeAlso, manPageName(commandPath(node.PathToCom, c.Name), sub.Name))
+	}
+	if seeAlso != nil {
+		buf.WriteString(".SH SEE ALSO\n")
+		for i, page := range seeAlso {
+			if i > 0 {
+				buf.WriteString(",\n")
+			}
+			buf.WriteString(fmt.Sprintf("\\fB%s\\fR(1)", roffEscape(page)))
+		}
+		buf.WriteString("\n")
+	}
+
+	if tree.Author != "" {
+		buf.WriteString(".SH AUTHOR\n")
+		author := tree.Author
+		if tree.Email != "" {
+			author += " <" + tree.Email + ">"
+		}
+		buf.WriteString(roffEscape(author) + "\n")
+	}
+
+	if tree.Copyright != "" {
+		buf.WriteString(".SH COPYRIGHT\n")
+		buf.WriteString(roffEscape(tree.Copyright) + "\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// manPageName joins the path to a command and its name with dashes, e.g. "the-quick-brown".
+func manPageName(pathToCom []string, name string) string {
+	return strings.Join(commandPath(pathToCom, name), "-")
+}
+
+func manNames(short string, long string) string {
+	switch {
+	case short != "" && long != "":
+		return fmt.Sprintf("-%s, --%s", short, long)
+	case short != "":
+		return "-" + short
+	}
+	return "--" + long
+}
+
+// writeRoffText writes text on its own line. Empty text is skipped because a blank line
+// adds vertical space in roff.
+func writeRoffText(buf *bytes.Buffer, text string) {
+	text = strings.TrimSpace(text)
+	if text != "" {
+		buf.WriteString(roffEscape(text) + "\n")
+	}
+}
+
+// roffEscape escapes text so that roff prints it as written.
+func roffEscape(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
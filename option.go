@@ -1,18 +1,136 @@
 package cli
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 type Option struct {
 	ShortName   string
 	LongName    string
 	Value       string
 	Description string
+
+	// Type converts Value when the command line is parsed. The result is stored in
+	// TypedValue. A nil Type leaves the value as a string.
+	Type       ValueParser
+	TypedValue interface{}
+
+	// Required makes Run fail before any PreAction when the option is not given.
+	Required bool
+
+	// Default is used as the Value when the user does not give the option. Source records
+	// where the Value of a parsed option came from.
+	Default string
+	Source  Source
+
+	// EnvVars are checked in order when the option is not on the command line. The first
+	// one that is set supplies the Value. Origin holds the variable a parsed option was
+	// read from.
+	EnvVars []string
+	Origin  string
+
+	// Choices are the only values the option accepts. They are shown in help and offered
+	// during shell completion, along with anything returned by Complete.
+	Choices  []string
+	Complete CompletionFunc
+
+	// Pattern is a regular expression the whole value must match, Range limits a numeric
+	// value, and Validate checks the value after it is converted to its Type. ParseArgs
+	// reports a value that breaks any of them as an error naming the option.
+	Pattern  string
+	Range    *Range
+	Validate ValidatorFunc
+
+	// Repeatable collects every value given for the option, in order, as its own entry in
+	// the parsed Opts. Separator also splits each value into several, e.g. "," for
+	// "--tag a,b". Duplicates decides what happens when an option that is not Repeatable
+	// is given more than once.
+	Repeatable bool
+	Separator  string
+	Duplicates DuplicatePolicy
+
+	// Persistent makes every command below the one defining the option inherit it. The user
+	// may also give it before the names of those commands.
+	Persistent bool
 }
 
+// DuplicatePolicy decides which value is kept when an Option that is not Repeatable is
+// given more than once.
+type DuplicatePolicy int
+
+const (
+	DuplicateFirstWins DuplicatePolicy = iota // keep the first value and ignore the rest
+	DuplicateLastWins                         // keep the last value
+	DuplicateError                            // fail to parse the command line
+)
+
 func (opt *Option) String() string {
 	return fmt.Sprintf("Opt: -%-2s --%-10s, %-s %-s", opt.ShortName, opt.LongName, opt.Description, opt.Value)
 }
 
+// name returns whichever of the long or short name is set, preferring the long name.
+func (opt *Option) name() string {
+	if opt.LongName != "" {
+		return opt.LongName
+	}
+	return opt.ShortName
+}
+
+// displayName returns the name of the option as the user would type it.
+func (opt *Option) displayName() string {
+	if opt.LongName != "" {
+		return "--" + opt.LongName
+	}
+	return "-" + opt.ShortName
+}
+
+func (opt *Option) rules() valueRules {
+	return valueRules{opt.Choices, opt.Pattern, opt.Range, opt.Validate}
+}
+
+// values splits a value given for the option into the values it holds.
+func (opt *Option) values(val string) []string {
+	if !opt.Repeatable || opt.Separator == "" {
+		return []string{val}
+	}
+	return strings.Split(val, opt.Separator)
+}
+
+// addOption adds the option o given by the user to userCom, following the Repeatable,
+// Separator, and Duplicates settings of its definition def.
+func addOption(userCom *Command, def Option, o Option) error {
+	if def.Repeatable {
+		for _, val := range def.values(o.Value) {
+			o.Value = val
+			userCom.Opts = append(userCom.Opts, o)
+		}
+		return nil
+	}
+
+	for i := range userCom.Opts {
+		if !sameOption(userCom.Opts[i], def) {
+			continue
+		}
+		switch def.Duplicates {
+		case DuplicateLastWins:
+			userCom.Opts[i] = o
+		case DuplicateError:
+			errStr := fmt.Sprintf("cli: Option %s given more than once", def.displayName())
+			return errors.New(errStr)
+		}
+		return nil
+	}
+	userCom.Opts = append(userCom.Opts, o)
+	return nil
+}
+
+// sameOption reports whether the parsed option o is an entry for the definition def.
+func sameOption(o Option, def Option) bool {
+	return (def.ShortName != "" && o.ShortName == def.ShortName) || (def.LongName != "" && o.LongName == def.LongName)
+}
+
 func OptArrayToStringArray(optArr []Option) (strArr []string) {
 	for _, opt := range optArr {
 		strArr = append(strArr, opt.String())
@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Source records where the value of a parsed Flag, Option, or Argument came from.
+type Source int
+
+const (
+	SourceUser    Source = iota // given on the command line
+	SourceEnv                   // read from one of the EnvVars of the definition
+	SourceConfig                // read from a config file
+	SourceDefault               // filled from the Default of the definition
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceUser:
+		return "user"
+	case SourceEnv:
+		return "env"
+	case SourceConfig:
+		return "config"
+	case SourceDefault:
+		return "default"
+	}
+	return "unknown"
+}
+
+// fillDefaults adds an entry to userCom for each Flag, Option, and Argument of c that has a
+// default and was not given by the user.
+func fillDefaults(c Command, userCom *Command) {
+	for _, f := range c.Flags {
+		if f.Default && !userCom.hasFlagDef(f) {
+			userCom.Flags = append(userCom.Flags, Flag{
+				ShortName: f.ShortName,
+				LongName:  f.LongName,
+				Source:    SourceDefault,
+			})
+		}
+	}
+
+	for _, o := range c.Opts {
+		if o.Default != "" && !userCom.hasOptionDef(o) {
+			for _, val := range o.values(o.Default) {
+				userCom.Opts = append(userCom.Opts, Option{
+					ShortName: o.ShortName,
+					LongName:  o.LongName,
+					Value:     val,
+					Source:    SourceDefault,
+				})
+			}
+		}
+	}
+
+	for _, a := range c.matchedArgs(userCom.MatchedArgSet) {
+		if a.Default != "" && userCom.countArg(a.Name) == 0 {
+			userCom.Args = append(userCom.Args, Argument{
+				Name:   a.Name,
+				Value:  a.Default,
+				Source: SourceDefault,
+			})
+		}
+	}
+}
+
+// resolveValues binds the positional arguments of userCom to the signature of c they match,
+// fills in the inputs the user left off the command line, and converts every value to its
+// Type. Values come from the environment first, then the config files, and
+// then the defaults of the definition. comPath is the path to the command without the root.
+func resolveValues(c Command, userCom *Command, conf configValues, comPath []string) error {
+	err := bindArgs(c, userCom)
+	if err != nil {
+		return err
+	}
+	err = fillEnv(c, userCom)
+	if err != nil {
+		return err
+	}
+	err = fillConfig(c, userCom, conf, comPath)
+	if err != nil {
+		return err
+	}
+	fillDefaults(c, userCom)
+	return convertValues(c, userCom)
+}
+
+// fillConfig adds an entry to userCom for each Flag and Option of c that was not given by
+// the user or the environment but has a value in conf.
+func fillConfig(c Command, userCom *Command, conf configValues, comPath []string) error {
+	for _, f := range c.Flags {
+		if userCom.hasFlagDef(f) {
+			continue
+		}
+		val, found := conf.lookup(comPath, f.name())
+		if !found {
+			continue
+		}
+		set, err := strconv.ParseBool(val.Value)
+		if err != nil {
+			errStr := fmt.Sprintf("cli: Invalid value %q for flag %s from %s: expected true or false", val.Value, f.displayName(), val.Path)
+			return errors.New(errStr)
+		}
+		userCom.Flags = append(userCom.Flags, Flag{
+			ShortName: f.ShortName,
+			LongName:  f.LongName,
+			Source:    SourceConfig,
+			Origin:    val.Path,
+			Negated:   !set,
+		})
+	}
+
+	for _, o := range c.Opts {
+		if userCom.hasOptionDef(o) {
+			continue
+		}
+		val, found := conf.lookup(comPath, o.name())
+		if !found {
+			continue
+		}
+		for _, v := range o.values(val.Value) {
+			userCom.Opts = append(userCom.Opts, Option{
+				ShortName: o.ShortName,
+				LongName:  o.LongName,
+				Value:     v,
+				Source:    SourceConfig,
+				Origin:    val.Path,
+			})
+		}
+	}
+	return nil
+}
+
+// fillEnv adds an entry to userCom for each Flag and Option of c that was not given by the
+// user but has one of its EnvVars set. The first variable that is set and not empty wins.
+func fillEnv(c Command, userCom *Command) error {
+	for _, f := range c.Flags {
+		if userCom.hasFlagDef(f) {
+			continue
+		}
+		envVar, val, found := lookupEnv(f.EnvVars)
+		if !found {
+			continue
+		}
+		set, err := strconv.ParseBool(val)
+		if err != nil {
+			errStr := fmt.Sprintf("cli: Invalid value %q for flag %s from %s: expected true or false", val, f.displayName(), envVar)
+			return errors.New(errStr)
+		}
+		userCom.Flags = append(userCom.Flags, Flag{
+			ShortName: f.ShortName,
+			LongName:  f.LongName,
+			Source:    SourceEnv,
+			Origin:    envVar,
+			Negated:   !set,
+		})
+	}
+
+	for _, o := range c.Opts {
+		if userCom.hasOptionDef(o) {
+			continue
+		}
+		envVar, val, found := lookupEnv(o.EnvVars)
+		if !found {
+			continue
+		}
+		for _, v := range o.values(val) {
+			userCom.Opts = append(userCom.Opts, Option{
+				ShortName: o.ShortName,
+				LongName:  o.LongName,
+				Value:     v,
+				Source:    SourceEnv,
+				Origin:    envVar,
+			})
+		}
+	}
+	return nil
+}
+
+func lookupEnv(envVars []string) (envVar string, val string, found bool) {
+	for _, envVar := range envVars {
+		if val := os.Getenv(envVar); val != "" {
+			return envVar, val, true
+		}
+	}
+	return "", "", false
+}
+
+// bindEnvVars gives each Flag and Option of fullCom that has no EnvVars of its own a name
+// derived from tree.EnvPrefix and the path to the command, e.g. MYTOOL_QUICK_BROWN_COLOR.
+// The root command is left out of the path because it is the name of the program.
+func (tree CommandTree) bindEnvVars(fullCom *Command, pathToCom []string) {
+	if tree.EnvPrefix == "" {
+		return
+	}
+	comPath := commandPath(pathToCom, fullCom.Name)[1:]
+
+	flags := make([]Flag, len(fullCom.Flags))
+	copy(flags, fullCom.Flags)
+	for i := range flags {
+		if flags[i].EnvVars == nil && !isAutoFlag(flags[i]) {
+			flags[i].EnvVars = []string{envVarName(tree.EnvPrefix, comPath, flags[i].name())}
+		}
+	}
+	fullCom.Flags = flags
+
+	opts := make([]Option, len(fullCom.Opts))
+	copy(opts, fullCom.Opts)
+	for i := range opts {
+		if opts[i].EnvVars == nil && !isAutoOption(opts[i]) {
+			opts[i].EnvVars = []string{envVarName(tree.EnvPrefix, comPath, opts[i].name())}
+		}
+	}
+	fullCom.Opts = opts
+}
+
+func envVarName(prefix string, comPath []string, name string) string {
+	parts := append(append([]string{}, comPath...), name)
+	name = strings.ToUpper(strings.Join(parts, "_"))
+	name = strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	return prefix + name
+}
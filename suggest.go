@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultSuggestionDistance is the largest edit distance at which a name is suggested when
+// the CommandTree does not set SuggestionDistance.
+const defaultSuggestionDistance = 2
+
+// suggestions returns the names that are within maxDistance edits of typed, closest first.
+// Names that start with typed come before others at the same distance.
+func suggestions(typed string, names []string, maxDistance int) (matches []string) {
+	distances := make(map[string]int)
+	lowerTyped := strings.ToLower(typed)
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if _, seen := distances[name]; seen {
+			continue
+		}
+		dist := editDistance(lowerTyped, strings.ToLower(name))
+		if dist <= maxDistance {
+			distances[name] = dist
+			matches = append(matches, name)
+		}
+	}
+	isPrefix := func(name string) bool {
+		return strings.HasPrefix(strings.ToLower(name), lowerTyped)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if distances[matches[i]] != distances[matches[j]] {
+			return distances[matches[i]] < distances[matches[j]]
+		}
+		return isPrefix(matches[i]) && !isPrefix(matches[j])
+	})
+	return matches
+}
+
+// nearest returns the names within maxDistance edits of typed that are closest to it.
+func nearest(typed string, names []string, maxDistance int) (matches []string) {
+	best := maxDistance + 1
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		dist := editDistance(strings.ToLower(typed), strings.ToLower(name))
+		if dist < best {
+			best = dist
+			matches = nil
+		}
+		if dist == best && !containsString(matches, name) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// editDistance returns the number of insertions, deletions, substitutions, and swaps of
+// adjacent characters needed to turn a into b.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	dist := make([][]int, len(ra)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(rb)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			dist[i][j] = minInt(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				dist[i][j] = minInt(dist[i][j], dist[i-2][j-2]+1)
+			}
+		}
+	}
+	return dist[len(ra)][len(rb)]
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
+
+// didYouMean formats a list of suggestions to be appended to an error message. It returns
+// an empty string when there are none.
+func didYouMean(matches []string, quote func(string) string) string {
+	switch len(matches) {
+	case 0:
+		return ""
+	case 1:
+		return "; did you mean " + quote(matches[0]) + "?"
+	}
+	var quoted []string
+	for _, m := range matches {
+		quoted = append(quoted, quote(m))
+	}
+	return "; did you mean one of " + strings.Join(quoted, ", ") + "?"
+}
+
+func singleQuote(str string) string {
+	return "'" + str + "'"
+}
+
+func longFormQuote(str string) string {
+	return "--" + str
+}
+
+// longNames returns the long names of every Flag and Option of c, including the "no-"
+// names of Negatable flags.
+func (c Command) longNames() (names []string) {
+	for _, f := range c.Flags {
+		names = append(names, f.LongName)
+		if f.Negatable && f.LongName != "" {
+			names = append(names, "no-"+f.LongName)
+		}
+	}
+	for _, o := range c.Opts {
+		names = append(names, o.LongName)
+	}
+	return names
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"os"
+	"strconv"
+)
+
+// TerminalWidth returns the width that help is wrapped to. It can be replaced to fix the
+// width, for example in tests. By default it asks the terminal attached to stdout and falls
+// back to $COLUMNS, and then to 80, when stdout is not a terminal.
+var TerminalWidth = terminalWidth
+
+// stdoutWidth asks the terminal attached to stdout for its width. Tests replace it to
+// stand in for a terminal.
+var stdoutWidth = ttyWidth
+
+func terminalWidth() int {
+	if width := stdoutWidth(); width > 0 {
+		return width
+	}
+	if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+		return width
+	}
+	return 80
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package cli
+
+// ttyWidth is not supported on this platform, so the width always comes from $COLUMNS.
+func ttyWidth() int {
+	return 0
+}
@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryanuber/columnize"
+)
+
+// Range limits a numeric Option or Argument to the values from Min to Max, inclusive.
+type Range struct {
+	Min float64
+	Max float64
+}
+
+// A ValidatorFunc checks the value of an Option or Argument after it has been converted to
+// its Type. It is given the TypedValue, and the error it returns is reported by ParseArgs.
+type ValidatorFunc func(val interface{}) error
+
+// valueRules are the checks an Option or Argument places on its value.
+type valueRules struct {
+	choices  []string
+	pattern  string
+	rng      *Range
+	validate ValidatorFunc
+}
+
+// check returns an error describing why the value str, converted to val, breaks the rules.
+func (rules valueRules) check(str string, val interface{}) error {
+	if rules.choices != nil && !containsString(rules.choices, str) {
+		return fmt.Errorf("expected one of %s", strings.Join(rules.choices, ", "))
+	}
+	if rules.pattern != "" {
+		re, err := regexp.Compile("^(?:" + rules.pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %s", rules.pattern, err.Error())
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("expected a value matching %s", rules.pattern)
+		}
+	}
+	if rules.rng != nil {
+		num, err := toFloat(str, val)
+		if err != nil {
+			return err
+		}
+		if num < rules.rng.Min || num > rules.rng.Max {
+			return fmt.Errorf("expected a number from %s to %s", formatFloat(rules.rng.Min), formatFloat(rules.rng.Max))
+		}
+	}
+	if rules.validate != nil {
+		return rules.validate(val)
+	}
+	return nil
+}
+
+func toFloat(str string, val interface{}) (num float64, err error) {
+	switch val := val.(type) {
+	case int:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case float64:
+		return val, nil
+	case time.Duration:
+		return float64(val), nil
+	}
+	num, err = strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, errors.New("expected a number")
+	}
+	return num, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// choicesString returns choices as shown in help, e.g. "{json,yaml,table}".
+func choicesString(choices []string) string {
+	return "{" + strings.Join(choices, ",") + "}"
+}
+
+// CheckRequired verifies that every Option and Argument of fullCom marked as Required was
+// given in userCom. The returned error lists each missing input along with its help text.
+func CheckRequired(fullCom Command, userCom Command) error {
+	var missingOpts []string
+	for _, o := range fullCom.Opts {
+		if o.Required && !userCom.hasOptionDef(o) {
+			missingOpts = append(missingOpts, toShortLongDescString(o.ShortName, o.LongName, o.Description))
+		}
+	}
+
+	var missingArgs []string
+	for _, a := range fullCom.matchedArgs(userCom.MatchedArgSet) {
+		if userCom.countArg(a.Name) < a.minValues() {
+			missingArgs = append(missingArgs, fmt.Sprintf("%s|%s", a.label(), a.Description))
+		}
+	}
+
+	if missingOpts == nil && missingArgs == nil {
+		return nil
+	}
+
+	var errBuf bytes.Buffer
+	config := columnize.DefaultConfig()
+	config.Prefix = "  "
+
+	errBuf.WriteString(fmt.Sprintf("cli: Missing required input for %s:", fullCom.Name))
+	if missingOpts != nil {
+		errBuf.WriteString("\n")
+		errBuf.WriteString(columnize.Format(missingOpts, config))
+	}
+	if missingArgs != nil {
+		errBuf.WriteString("\n")
+		errBuf.WriteString(columnize.Format(missingArgs, config))
+	}
+	return errors.New(errBuf.String())
+}
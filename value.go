@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ValueParser converts the string given by the user for an Option or Argument into a
+// typed value. ParseArgs stores the result in the TypedValue field of the parsed input.
+type ValueParser interface {
+	Parse(str string) (val interface{}, err error)
+}
+
+// ParserFunc allows an ordinary function to be used as a ValueParser.
+type ParserFunc func(str string) (val interface{}, err error)
+
+func (f ParserFunc) Parse(str string) (val interface{}, err error) {
+	return f(str)
+}
+
+// Built in value types. The comment on each gives the Go type stored in TypedValue.
+var (
+	IntType          ValueParser = ParserFunc(parseInt)          // int
+	Int64Type        ValueParser = ParserFunc(parseInt64)        // int64
+	FloatType        ValueParser = ParserFunc(parseFloat)        // float64
+	BoolType         ValueParser = ParserFunc(parseBool)         // bool
+	DurationType     ValueParser = ParserFunc(parseDuration)     // time.Duration
+	StringSliceType  ValueParser = ParserFunc(parseStringSlice)  // []string, from "a,b,c"
+	MapType          ValueParser = ParserFunc(parseMap)          // map[string]string, from "k1=v1,k2=v2"
+	URLType          ValueParser = ParserFunc(parseURL)          // *url.URL
+	FilePathType     ValueParser = ParserFunc(parseFilePath)     // string, cleaned with filepath.Clean
+	ExistingFileType ValueParser = ParserFunc(parseExistingFile) // string, the file must exist
+)
+
+func parseInt(str string) (val interface{}, err error) {
+	i, err := strconv.ParseInt(str, 10, strconv.IntSize)
+	if err != nil {
+		return nil, errors.New("expected an integer")
+	}
+	return int(i), nil
+}
+
+func parseInt64(str string) (val interface{}, err error) {
+	i, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return nil, errors.New("expected a 64 bit integer")
+	}
+	return i, nil
+}
+
+func parseFloat(str string) (val interface{}, err error) {
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, errors.New("expected a number")
+	}
+	return f, nil
+}
+
+func parseBool(str string) (val interface{}, err error) {
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		return nil, errors.New("expected true or false")
+	}
+	return b, nil
+}
+
+func parseDuration(str string) (val interface{}, err error) {
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return nil, errors.New("expected a duration such as 300ms, 1.5h or 2h45m")
+	}
+	return d, nil
+}
+
+func parseStringSlice(str string) (val interface{}, err error) {
+	if str == "" {
+		return []string{}, nil
+	}
+	return strings.Split(str, ","), nil
+}
+
+func parseMap(str string) (val interface{}, err error) {
+	m := make(map[string]string)
+	if str == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(str, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("expected key=value but found %q", pair)
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m, nil
+}
+
+func parseURL(str string) (val interface{}, err error) {
+	u, err := url.Parse(str)
+	if err != nil || u.Scheme == "" {
+		return nil, errors.New("expected an absolute URL")
+	}
+	return u, nil
+}
+
+func parseFilePath(str string) (val interface{}, err error) {
+	if str == "" {
+		return nil, errors.New("expected a file path")
+	}
+	return filepath.Clean(str), nil
+}
+
+func parseExistingFile(str string) (val interface{}, err error) {
+	if str == "" {
+		return nil, errors.New("expected a file path")
+	}
+	path := filepath.Clean(str)
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("file %s does not exist", path)
+	}
+	return path, nil
+}
+
+// convertValues runs the ValueParser of each definition in c over the matching input in
+// userCom and checks the result against the Choices, Pattern, Range, and Validate of the
+// definition. Inputs without a Type are stored as plain strings.
+func convertValues(c Command, userCom *Command) error {
+	for i := range userCom.Opts {
+		opt := &userCom.Opts[i]
+		def, _ := c.findOption(opt.name())
+		val, err := convertValue(def.Type, opt.Value)
+		if err == nil {
+			err = def.rules().check(opt.Value, val)
+		}
+		if err != nil {
+			from := ""
+			if opt.Origin != "" {
+				from = " from " + opt.Origin
+			}
+			return fmt.Errorf("cli: Invalid value %q for option %s%s: %s", opt.Value, def.displayName(), from, err.Error())
+		}
+		opt.TypedValue = val
+	}
+
+	defs := c.matchedArgs(userCom.MatchedArgSet)
+	for i := range userCom.Args {
+		arg := &userCom.Args[i]
+		if c.hasArg(arg.Value) {
+			arg.TypedValue = arg.Value
+			continue
+		}
+		var def Argument
+		for _, d := range defs {
+			if d.Name == arg.Name {
+				def = d
+				break
+			}
+		}
+		val, err := convertValue(def.Type, arg.Value)
+		if err == nil {
+			err = def.rules().check(arg.Value, val)
+		}
+		if err != nil {
+			return fmt.Errorf("cli: Invalid value %q for argument <%s>: %s", arg.Value, def.Name, err.Error())
+		}
+		arg.TypedValue = val
+	}
+	return nil
+}
+
+func convertValue(parser ValueParser, str string) (val interface{}, err error) {
+	if parser == nil {
+		return str, nil
+	}
+	return parser.Parse(str)
+}